@@ -0,0 +1,125 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrBatchWriterClosed is returned when writing to a batch writer that has been closed.
+var ErrBatchWriterClosed = errors.New("batch writer is closed")
+
+// BatchWriter accumulates formatted entries and writes them to the underlying writer in batches.
+//
+// Each call to `Write` is treated as one complete entry (logrus writes each entry with a single call), so a batch
+// only ever contains whole entries; the output is newline-delimited JSON.
+// A batch is written when it reaches the size threshold, when the flush interval elapses, and when the writer is closed.
+//
+// This is safe for concurrent use.
+type BatchWriter struct {
+	writer   io.Writer
+	maxBytes int
+
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	closed bool
+	done   chan struct{}
+	wait   sync.WaitGroup
+}
+
+// NewBatchWriter creates a new batch writer.
+//
+// If `maxBytes` is positive, then a batch is written once it reaches that many bytes.
+// If `interval` is positive, then any pending entries are written at that interval.
+// Call `Close` to write the remaining entries and stop the interval.
+func NewBatchWriter(writer io.Writer, maxBytes int, interval time.Duration) *BatchWriter {
+	w := &BatchWriter{
+		writer:   writer,
+		maxBytes: maxBytes,
+		done:     make(chan struct{}),
+	}
+	if interval > 0 {
+		w.wait.Add(1)
+		go w.flushEvery(interval)
+	}
+	return w
+}
+
+// flushEvery flushes the writer at the given interval until it is closed.
+func (w *BatchWriter) flushEvery(interval time.Duration) {
+	defer w.wait.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			_ = w.Flush()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Write adds an entry to the current batch.
+func (w *BatchWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, ErrBatchWriterClosed
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	// Write the pending entries first if this entry would push the batch over the threshold.
+	if w.maxBytes > 0 && w.buffer.Len() > 0 && w.buffer.Len()+len(p) > w.maxBytes {
+		if err := w.flush(); err != nil {
+			return 0, err
+		}
+	}
+	w.buffer.Write(p)
+	if w.maxBytes > 0 && w.buffer.Len() >= w.maxBytes {
+		if err := w.flush(); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the pending entries.
+func (w *BatchWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.flush()
+}
+
+// flush writes the pending entries; the mutex must be held.
+func (w *BatchWriter) flush() error {
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	_, err := w.writer.Write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// Close writes the pending entries and stops the flush interval.
+//
+// Any further writes return `ErrBatchWriterClosed`.
+func (w *BatchWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	err := w.flush()
+	w.mutex.Unlock()
+
+	w.wait.Wait()
+	return err
+}
@@ -0,0 +1,146 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingWriter records each write that it receives.
+type recordingWriter struct {
+	mutex  sync.Mutex
+	writes []string
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.writes = append(w.writes, string(p))
+	return len(p), nil
+}
+
+func (w *recordingWriter) Writes() []string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]string(nil), w.writes...)
+}
+
+// requireWholeEntries asserts that every line of every write is a complete JSON object.
+func requireWholeEntries(t *testing.T, writes []string) int {
+	count := 0
+	for _, write := range writes {
+		require.True(t, strings.HasSuffix(write, "\n"))
+		for _, line := range strings.Split(strings.TrimSuffix(write, "\n"), "\n") {
+			require.True(t, json.Valid([]byte(line)), line)
+			count++
+		}
+	}
+	return count
+}
+
+func TestBatchWriter(t *testing.T) {
+	newLogger := func(writer *BatchWriter) *logrus.Logger {
+		logger := logrus.New()
+		logger.SetFormatter(New(WithDisableTimestamp(true)))
+		logger.SetOutput(writer)
+		return logger
+	}
+	entry := `{"message":"test","severity":"Info"}` + "\n"
+
+	t.Run("Size", func(t *testing.T) {
+		output := &recordingWriter{}
+		writer := NewBatchWriter(output, 2*len(entry)+1, 0)
+		logger := newLogger(writer)
+
+		logger.Info("test")
+		logger.Info("test")
+		assert.Empty(t, output.Writes())
+
+		logger.Info("test")
+		assert.Equal(t, []string{entry + entry}, output.Writes())
+
+		require.Nil(t, writer.Close())
+		assert.Equal(t, []string{entry + entry, entry}, output.Writes())
+	})
+	t.Run("Size Exactly", func(t *testing.T) {
+		output := &recordingWriter{}
+		writer := NewBatchWriter(output, 2*len(entry), 0)
+		logger := newLogger(writer)
+
+		logger.Info("test")
+		assert.Empty(t, output.Writes())
+
+		logger.Info("test")
+		assert.Equal(t, []string{entry + entry}, output.Writes())
+		require.Nil(t, writer.Close())
+		assert.Equal(t, []string{entry + entry}, output.Writes())
+	})
+	t.Run("Interval", func(t *testing.T) {
+		output := &recordingWriter{}
+		writer := NewBatchWriter(output, 0, 10*time.Millisecond)
+		defer writer.Close()
+		logger := newLogger(writer)
+
+		logger.Info("test")
+		logger.Info("test")
+		require.Eventually(t, func() bool {
+			return strings.Join(output.Writes(), "") == entry+entry
+		}, time.Second, 5*time.Millisecond)
+	})
+	t.Run("Close", func(t *testing.T) {
+		output := &recordingWriter{}
+		writer := NewBatchWriter(output, 0, time.Hour)
+		logger := newLogger(writer)
+
+		logger.Info("test")
+		assert.Empty(t, output.Writes())
+
+		require.Nil(t, writer.Close())
+		assert.Equal(t, []string{entry}, output.Writes())
+
+		_, err := writer.Write([]byte(entry))
+		assert.Equal(t, ErrBatchWriterClosed, err)
+		require.Nil(t, writer.Close())
+	})
+	t.Run("Concurrent", func(t *testing.T) {
+		output := &recordingWriter{}
+		writer := NewBatchWriter(output, 512, time.Millisecond)
+		logger := newLogger(writer)
+
+		var wait sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wait.Add(1)
+			go func(i int) {
+				defer wait.Done()
+				for j := 0; j < 100; j++ {
+					logger.WithField("value", fmt.Sprintf("%d-%d", i, j)).Info(strings.Repeat("x", j))
+				}
+			}(i)
+		}
+		wait.Wait()
+		require.Nil(t, writer.Close())
+
+		assert.Equal(t, 1000, requireWholeEntries(t, output.Writes()))
+	})
+}
+
+func TestBatchWriterFlush(t *testing.T) {
+	var output bytes.Buffer
+	writer := NewBatchWriter(&output, 0, 0)
+
+	_, err := writer.Write([]byte("{}\n"))
+	require.Nil(t, err)
+	assert.Equal(t, "", output.String())
+
+	require.Nil(t, writer.Flush())
+	assert.Equal(t, "{}\n", output.String())
+	require.Nil(t, writer.Close())
+}
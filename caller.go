@@ -0,0 +1,89 @@
+package gcfstructuredlogformatter
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SourceLocation is the location in the source code where the log entry was emitted.
+//
+// This is normally determined from the caller (see `ReportCaller`), but it may also be provided explicitly
+// as a logrus field named `SourceLocationKey` (see `WithSourceLocation`), in which case the stack is not walked.
+//
+// See: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntrySourceLocation
+type SourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,string,omitempty"`
+	Function string `json:"function,omitempty"`
+}
+
+// WithSourceLocation adds the source location to the entry as a logrus field named `SourceLocationKey`.
+func WithSourceLocation(entry *logrus.Entry, sourceLocation SourceLocation) *logrus.Entry {
+	return entry.WithField(SourceLocationKey, sourceLocation)
+}
+
+// hasSourceLocation returns true if the fields have an explicit source location.
+func hasSourceLocation(fields logrus.Fields) bool {
+	switch fields[SourceLocationKey].(type) {
+	case SourceLocation, *SourceLocation:
+		return true
+	}
+	return false
+}
+
+const (
+	// logrusPackage is the package name of logrus; its frames are skipped when walking the stack.
+	logrusPackage = "github.com/sirupsen/logrus"
+	// maximumCallerDepth is the maximum number of frames to walk when looking for the caller.
+	maximumCallerDepth = 25
+)
+
+var (
+	// formatterPackage is the package name of this package; its frames are skipped when walking the stack.
+	formatterPackage = reflect.TypeOf((*Formatter)(nil)).Elem().PkgPath()
+	// callerPool is a pool of program counter slices for walking the stack.
+	callerPool = sync.Pool{
+		New: func() interface{} {
+			pcs := make([]uintptr, maximumCallerDepth)
+			return &pcs
+		},
+	}
+)
+
+// getPackageName returns the package name of a fully-qualified function name.
+func getPackageName(function string) string {
+	for {
+		lastPeriod := strings.LastIndex(function, ".")
+		lastSlash := strings.LastIndex(function, "/")
+		if lastPeriod > lastSlash {
+			function = function[:lastPeriod]
+		} else {
+			break
+		}
+	}
+	return function
+}
+
+// getCaller walks the stack and returns the first frame outside of logrus and this package.
+func getCaller() *runtime.Frame {
+	pcs := callerPool.Get().(*[]uintptr)
+	defer callerPool.Put(pcs)
+
+	depth := runtime.Callers(2, *pcs)
+	frames := runtime.CallersFrames((*pcs)[:depth])
+	for {
+		frame, more := frames.Next()
+		packageName := getPackageName(frame.Function)
+		if packageName != logrusPackage && packageName != formatterPackage {
+			return &frame
+		}
+		if !more {
+			break
+		}
+	}
+	return nil
+}
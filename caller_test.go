@@ -0,0 +1,117 @@
+package gcfstructuredlogformatter_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+)
+
+func TestFormatReportCaller(t *testing.T) {
+	decode := func(t *testing.T, contents []byte) map[string]interface{} {
+		output := map[string]interface{}{}
+		err := json.Unmarshal(contents, &output)
+		require.Nil(t, err)
+		return output
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetFormatter(gcfstructuredlogformatter.New())
+		logger.Info("test")
+
+		output := decode(t, buffer.Bytes())
+		assert.NotContains(t, output, gcfstructuredlogformatter.SourceLocationKey)
+	})
+
+	t.Run("Disabled with logrus caller", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetReportCaller(true)
+		logger.SetFormatter(gcfstructuredlogformatter.New())
+		logger.Info("test")
+
+		output := decode(t, buffer.Bytes())
+		assert.NotContains(t, output, gcfstructuredlogformatter.SourceLocationKey)
+	})
+
+	t.Run("Stack", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := gcfstructuredlogformatter.New()
+		formatter.ReportCaller = true
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetFormatter(formatter)
+		pc, file, line, _ := runtime.Caller(0)
+		logger.Info("test")
+
+		output := decode(t, buffer.Bytes())
+		assert.Equal(t, map[string]interface{}{
+			"file":     file,
+			"line":     strconv.Itoa(line + 1),
+			"function": runtime.FuncForPC(pc).Name(),
+		}, output[gcfstructuredlogformatter.SourceLocationKey])
+	})
+
+	t.Run("Entry caller", func(t *testing.T) {
+		formatter := gcfstructuredlogformatter.New()
+		formatter.ReportCaller = true
+		entry := logrus.NewEntry(logrus.New())
+		entry.Level = logrus.InfoLevel
+		entry.Caller = &runtime.Frame{
+			File:     "/path/to/file.go",
+			Line:     42,
+			Function: "example.com/package.Function",
+		}
+		result, err := formatter.Format(entry)
+		require.Nil(t, err)
+		assert.Equal(t, []byte(`{"logging.googleapis.com/sourceLocation":{"file":"/path/to/file.go","line":"42","function":"example.com/package.Function"},"message":"","severity":"Info"}`+"\n"), result)
+	})
+
+	t.Run("Explicit", func(t *testing.T) {
+		formatter := gcfstructuredlogformatter.New()
+		entry := gcfstructuredlogformatter.WithSourceLocation(logrus.NewEntry(logrus.New()), gcfstructuredlogformatter.SourceLocation{
+			File:     "/path/to/remote.go",
+			Line:     7,
+			Function: "example.com/remote.Function",
+		})
+		entry.Level = logrus.InfoLevel
+		result, err := formatter.Format(entry)
+		require.Nil(t, err)
+		assert.Equal(t, []byte(`{"logging.googleapis.com/sourceLocation":{"file":"/path/to/remote.go","line":"7","function":"example.com/remote.Function"},"message":"","severity":"Info"}`+"\n"), result)
+	})
+
+	t.Run("Explicit wins over caller", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := gcfstructuredlogformatter.New(gcfstructuredlogformatter.WithReportCaller(true), gcfstructuredlogformatter.WithDisableTimestamp(true))
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetReportCaller(true)
+		logger.SetFormatter(formatter)
+
+		gcfstructuredlogformatter.WithSourceLocation(logrus.NewEntry(logger), gcfstructuredlogformatter.SourceLocation{
+			File:     "/path/to/remote.go",
+			Line:     7,
+			Function: "example.com/remote.Function",
+		}).Info("explicit")
+		logger.Info("caller")
+
+		lines := bytes.Split(bytes.TrimSpace(buffer.Bytes()), []byte("\n"))
+		require.Len(t, lines, 2)
+		assert.Equal(t, `{"logging.googleapis.com/sourceLocation":{"file":"/path/to/remote.go","line":"7","function":"example.com/remote.Function"},"message":"explicit","severity":"Info"}`, string(lines[0]))
+
+		output := decode(t, lines[1])
+		sourceLocation, okay := output[gcfstructuredlogformatter.SourceLocationKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Contains(t, sourceLocation["file"], "caller_test.go")
+	})
+}
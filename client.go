@@ -0,0 +1,106 @@
+package gcfstructuredlogformatter
+
+import (
+	"cloud.google.com/go/logging"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+// ToEntry converts a logrus entry into an entry for the Cloud Logging client (`logging.Logger.Log`).
+//
+// The entry gets the same severity, labels, trace, and fields that `Format` would write.
+// The fields that the client has a place for (such as the trace and source location) are set on the entry;
+// everything else (including the message) is the entry's payload.
+//
+// If the entry is nil, then this returns `ErrNilEntry`.
+func (f *Formatter) ToEntry(entry *logrus.Entry) (logging.Entry, error) {
+	mapEntry, err := f.BuildEntry(entry)
+	if err != nil {
+		return logging.Entry{}, err
+	}
+
+	result := logging.Entry{
+		Severity: f.entrySeverity(entry),
+	}
+	delete(mapEntry, f.fieldKey(SeverityKey))
+
+	if _, okay := mapEntry[f.fieldKey(TimestampKey)]; okay {
+		result.Timestamp = entry.Time
+		delete(mapEntry, f.fieldKey(TimestampKey))
+	}
+	if insertID, okay := mapEntry[InsertIDKey].(string); okay {
+		result.InsertID = insertID
+		delete(mapEntry, InsertIDKey)
+	}
+	if trace, okay := mapEntry[f.traceKey()].(string); okay {
+		result.Trace = trace
+		delete(mapEntry, f.traceKey())
+	}
+	if spanID, okay := mapEntry[f.spanKey()].(string); okay {
+		result.SpanID = spanID
+		delete(mapEntry, f.spanKey())
+	}
+	if sampled, okay := mapEntry[TraceSampledKey].(bool); okay {
+		result.TraceSampled = sampled
+		delete(mapEntry, TraceSampledKey)
+	}
+	// The client has its own place for the labels, so they are always set there, whatever the `LabelsMode`.
+	if labels := f.labels(entry, entryFields(entry)); labels != nil {
+		result.Labels = labels
+		switch f.LabelsMode {
+		case LabelsPlain:
+			delete(mapEntry, PlainLabelsKey)
+		case LabelsBoth:
+			delete(mapEntry, LabelsKey)
+			delete(mapEntry, PlainLabelsKey)
+		case LabelsFlat:
+			for _, key := range flatLabelKeys(labels) {
+				delete(mapEntry, key)
+			}
+		default:
+			delete(mapEntry, LabelsKey)
+		}
+	}
+	var sourceLocation *SourceLocation
+	switch value := mapEntry[SourceLocationKey].(type) {
+	case SourceLocation:
+		sourceLocation = &value
+	case *SourceLocation:
+		sourceLocation = value
+	}
+	if sourceLocation != nil {
+		result.SourceLocation = &loggingpb.LogEntrySourceLocation{
+			File:     sourceLocation.File,
+			Line:     int64(sourceLocation.Line),
+			Function: sourceLocation.Function,
+		}
+		delete(mapEntry, SourceLocationKey)
+	}
+	var operation *Operation
+	switch value := mapEntry[OperationKey].(type) {
+	case Operation:
+		operation = &value
+	case *Operation:
+		operation = value
+	}
+	if operation != nil {
+		result.Operation = &loggingpb.LogEntryOperation{
+			Id:       operation.ID,
+			Producer: operation.Producer,
+			First:    operation.First,
+			Last:     operation.Last,
+		}
+		delete(mapEntry, OperationKey)
+	}
+	if resource, okay := mapEntry[ResourceKey].(*Resource); okay {
+		result.Resource = &monitoredres.MonitoredResource{
+			Type:   resource.Type,
+			Labels: resource.Labels,
+		}
+		delete(mapEntry, ResourceKey)
+	}
+
+	result.Payload = mapEntry
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToEntry(t *testing.T) {
+	logger := logrus.New()
+
+	t.Run("Full", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Sampled: true})
+		ctx = context.WithValue(ctx, ContextKeyOperation, &Operation{ID: "op", Producer: "producer", First: true})
+		e := logger.WithContext(ctx).WithFields(logrus.Fields{"key": "value", "count": 2})
+		e.Message = "test"
+		e.Level = logrus.WarnLevel
+		e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+		e.Caller = &runtime.Frame{File: "file.go", Line: 42, Function: "function"}
+
+		formatter := New(
+			WithProjectID("my-project"),
+			WithLabels(map[string]string{"label": "value"}),
+			WithInsertIDFunc(func(*logrus.Entry) string { return "insert" }),
+			WithReportCaller(true),
+			WithResource("global", map[string]string{"project_id": "my-project"}),
+		)
+		result, err := formatter.ToEntry(e)
+		require.Nil(t, err)
+
+		assert.Equal(t, logging.Warning, result.Severity)
+		assert.Equal(t, time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC), result.Timestamp)
+		assert.Equal(t, "insert", result.InsertID)
+		assert.Equal(t, "projects/my-project/traces/105445aa7843bc8bf206b12000100000", result.Trace)
+		assert.Equal(t, "0000000000000001", result.SpanID)
+		assert.True(t, result.TraceSampled)
+		assert.Equal(t, map[string]string{"label": "value"}, result.Labels)
+
+		require.NotNil(t, result.SourceLocation)
+		assert.Equal(t, "file.go", result.SourceLocation.File)
+		assert.Equal(t, int64(42), result.SourceLocation.Line)
+		assert.Equal(t, "function", result.SourceLocation.Function)
+
+		require.NotNil(t, result.Operation)
+		assert.Equal(t, "op", result.Operation.Id)
+		assert.Equal(t, "producer", result.Operation.Producer)
+		assert.True(t, result.Operation.First)
+		assert.False(t, result.Operation.Last)
+
+		require.NotNil(t, result.Resource)
+		assert.Equal(t, "global", result.Resource.Type)
+		assert.Equal(t, map[string]string{"project_id": "my-project"}, result.Resource.Labels)
+
+		assert.Equal(t, map[string]interface{}{"message": "test", "key": "value", "count": 2}, result.Payload)
+	})
+	t.Run("Minimal", func(t *testing.T) {
+		e := logrus.NewEntry(logger)
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		result, err := New().ToEntry(e)
+		require.Nil(t, err)
+
+		assert.Equal(t, logging.Info, result.Severity)
+		assert.True(t, result.Timestamp.IsZero())
+		assert.Equal(t, "", result.InsertID)
+		assert.Equal(t, "", result.Trace)
+		assert.Equal(t, "", result.SpanID)
+		assert.False(t, result.TraceSampled)
+		assert.Nil(t, result.Labels)
+		assert.Nil(t, result.SourceLocation)
+		assert.Nil(t, result.Operation)
+		assert.Nil(t, result.Resource)
+		assert.Equal(t, map[string]interface{}{"message": "test"}, result.Payload)
+	})
+	t.Run("Labels Mode", func(t *testing.T) {
+		for _, labelsMode := range []LabelsMode{LabelsStructured, LabelsPlain, LabelsBoth, LabelsFlat} {
+			ctx := context.WithValue(context.Background(), ContextKeyFields, logrus.Fields{"tenant": "acme"})
+			e := logger.WithContext(ctx).WithField("key", "value")
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(WithLabelsMode(labelsMode), WithLabels(map[string]string{"label": "value"}), WithLabelKeys("tenant"))
+			result, err := formatter.ToEntry(e)
+			require.Nil(t, err)
+			assert.Equal(t, map[string]string{"label": "value", "tenant": "acme"}, result.Labels, "mode: %v", labelsMode)
+			assert.Equal(t, map[string]interface{}{"message": "test", "key": "value"}, result.Payload, "mode: %v", labelsMode)
+		}
+	})
+
+	t.Run("Flat Labels with Prefixed Field", func(t *testing.T) {
+		e := logger.WithField("label.x", "field")
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"a": "1"}))
+		result, err := formatter.ToEntry(e)
+		require.Nil(t, err)
+		assert.Equal(t, map[string]string{"a": "1"}, result.Labels)
+		assert.Equal(t, map[string]interface{}{"message": "test", "fields.label.x": "field"}, result.Payload)
+
+		// Only the labels' own keys are removed from the payload.
+		formatter = New(WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"a": "1"}), WithReservedKeyPolicy(ReservedKeyOverwrite))
+		result, err = formatter.ToEntry(e)
+		require.Nil(t, err)
+		assert.Equal(t, map[string]string{"a": "1"}, result.Labels)
+		assert.Equal(t, map[string]interface{}{"message": "test", "label.x": "field"}, result.Payload)
+	})
+
+	t.Run("Source Location Field", func(t *testing.T) {
+		for _, sourceLocation := range []interface{}{SourceLocation{File: "file.go", Line: 7, Function: "function"}, &SourceLocation{File: "file.go", Line: 7, Function: "function"}} {
+			e := logger.WithField(SourceLocationKey, sourceLocation)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			result, err := New().ToEntry(e)
+			require.Nil(t, err)
+			require.NotNil(t, result.SourceLocation)
+			assert.Equal(t, "file.go", result.SourceLocation.File)
+			assert.Equal(t, int64(7), result.SourceLocation.Line)
+			assert.Equal(t, "function", result.SourceLocation.Function)
+			assert.Equal(t, map[string]interface{}{"message": "test"}, result.Payload)
+		}
+	})
+
+	t.Run("Nil Source Location Field", func(t *testing.T) {
+		e := logger.WithField(SourceLocationKey, (*SourceLocation)(nil))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		result, err := New().ToEntry(e)
+		require.Nil(t, err)
+		assert.Nil(t, result.SourceLocation)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		e := logger.WithField("key", "value")
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithValueMarshaler(func(key string, value interface{}) (interface{}, error) {
+			return nil, assert.AnError
+		}))
+		_, err := formatter.ToEntry(e)
+		assert.ErrorIs(t, err, assert.AnError)
+	})
+
+	t.Run("Nil Entry", func(t *testing.T) {
+		_, err := New().ToEntry(nil)
+		assert.ErrorIs(t, err, ErrNilEntry)
+	})
+}
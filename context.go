@@ -0,0 +1,80 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// contextKey is the type for the context keys used by this package.
+type contextKey string
+
+const (
+	// ContextKeyOperation is the context key for the operation.
+	// The value must be an `Operation` or `*Operation`.
+	ContextKeyOperation = contextKey("operation")
+	// ContextKeyHTTPRequest is the context key for the HTTP request.
+	// The value must be an `HTTPRequest` or `*HTTPRequest`.
+	ContextKeyHTTPRequest = contextKey("httpRequest")
+	// ContextKeyTraceContext is the context key for the trace information.
+	// The value must be a `TraceContext` or `*TraceContext`.
+	//
+	// This is only used when there is no valid OpenTelemetry span in the context (unless the formatter's
+	// `TracePrecedence` is `PreferContextKey`).
+	ContextKeyTraceContext = contextKey("traceContext")
+	// ContextKeyTraceparent is the context key for a W3C "traceparent" header value.
+	// The value must be a `string`; see `TraceFromTraceparent`.
+	//
+	// This is only used when there is no `ContextKeyTraceContext` value in the context, nor a valid OpenTelemetry span
+	// (unless the formatter's `TracePrecedence` is `PreferContextKey`).
+	ContextKeyTraceparent = contextKey("traceparent")
+	// ContextKeyFields is the context key for additional fields that apply to every entry in the context.
+	// The value must be a `map[string]interface{}` or `logrus.Fields`; the entry's own fields take precedence.
+	ContextKeyFields = contextKey("fields")
+	// ContextKeyTrace is the legacy context key for the trace.
+	// The value must be a `string` holding either a trace ID (32 hexadecimal characters) or a fully-qualified trace
+	// ("projects/PROJECT_ID/traces/TRACE_ID"); any other value is ignored.
+	//
+	// This is only used when there is no other trace from the context keys, nor a valid OpenTelemetry span
+	// (unless the formatter's `TracePrecedence` is `PreferContextKey`); prefer `WithTrace`.
+	ContextKeyTrace = contextKey("trace")
+	// ContextKeySeverity is the context key for a severity that overrides the one mapped from the logrus level.
+	// The value must be a `logging.Severity` or its name (such as "Info"); an invalid value is ignored.
+	ContextKeySeverity = contextKey("severity")
+)
+
+// entryFields returns the fields to write for the entry.
+//
+// These are the `ContextKeyFields` fields (if any), overridden by the entry's own fields.
+func entryFields(entry *logrus.Entry) logrus.Fields {
+	if entry.Context == nil {
+		return entry.Data
+	}
+	var contextFields map[string]interface{}
+	switch fields := entry.Context.Value(ContextKeyFields).(type) {
+	case map[string]interface{}:
+		contextFields = fields
+	case logrus.Fields:
+		contextFields = fields
+	}
+	if len(contextFields) == 0 {
+		return entry.Data
+	}
+
+	fields := make(logrus.Fields, len(contextFields)+len(entry.Data))
+	for key, value := range contextFields {
+		fields[key] = value
+	}
+	for key, value := range entry.Data {
+		fields[key] = value
+	}
+	return fields
+}
+
+// severityFromContext returns the `ContextKeySeverity` value from the context.
+//
+// If there is no valid severity, then this returns false.
+func severityFromContext(ctx context.Context) (logging.Severity, bool) {
+	return parseSeverity(ctx.Value(ContextKeySeverity))
+}
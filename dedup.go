@@ -0,0 +1,158 @@
+package gcfstructuredlogformatter
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// RepeatedKey is the key for the number of duplicate entries that were suppressed (see `Formatter.DedupWindow`).
+const RepeatedKey = "repeated"
+
+// DedupKeyFunc returns the key by which duplicate entries are recognized (see `Formatter.DedupWindow`).
+type DedupKeyFunc func(entry *logrus.Entry) string
+
+// DefaultDedupKey returns a key made up of the entry's level and message.
+func DefaultDedupKey(entry *logrus.Entry) string {
+	return entry.Level.String() + "\x00" + entry.Message
+}
+
+// dedupRecord is the state for one dedup key.
+type dedupRecord struct {
+	start      time.Time      // This is when the last entry with this key was written.
+	sequence   uint64         // This orders the records that have the same start.
+	suppressed int            // This is the number of entries with this key that have been suppressed since then.
+	level      logrus.Level   // This is the level of the last entry with this key that was written.
+	message    string         // This is the message of the last entry with this key that was written.
+	caller     *runtime.Frame // This is the caller of the last entry with this key that was written, if logrus reported it.
+}
+
+// dedupSummary is a count of suppressed entries whose window closed without another entry with the same key.
+type dedupSummary struct {
+	start    time.Time
+	sequence uint64
+	level    logrus.Level
+	message  string
+	caller   *runtime.Frame
+	repeated int
+}
+
+// deduplicator keeps track of the entries that have been written recently.
+type deduplicator struct {
+	mutex       sync.Mutex
+	records     map[string]*dedupRecord
+	lastSweep   time.Time
+	sequence    uint64    // This is the sequence number of the last record.
+	nextSummary time.Time // This is when the earliest window with suppressed entries closes; if zero, then there are none.
+}
+
+// check returns true if an entry with the given key should be suppressed; otherwise, it returns the number of
+// entries with the key that were suppressed since the last one was written.
+//
+// If `summarize` is set, then this also returns a summary for each other key whose window has closed with suppressed entries.
+func (d *deduplicator) check(key string, entry *logrus.Entry, window time.Duration, now time.Time, summarize bool) (repeated int, suppressed bool, summaries []dedupSummary) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.records == nil {
+		d.records = map[string]*dedupRecord{}
+		d.lastSweep = now
+	}
+
+	if summarize && !d.nextSummary.IsZero() && !now.Before(d.nextSummary) {
+		d.nextSummary = time.Time{}
+		for k, record := range d.records {
+			if k == key || record.suppressed == 0 {
+				continue
+			}
+			if closes := record.start.Add(window); now.Before(closes) {
+				d.deferSummary(closes)
+				continue
+			}
+			summaries = append(summaries, dedupSummary{start: record.start, sequence: record.sequence, level: record.level, message: record.message, caller: record.caller, repeated: record.suppressed})
+			record.suppressed = 0
+		}
+		sort.Slice(summaries, func(i, j int) bool {
+			if !summaries[i].start.Equal(summaries[j].start) {
+				return summaries[i].start.Before(summaries[j].start)
+			}
+			return summaries[i].sequence < summaries[j].sequence
+		})
+	}
+
+	// Forget the keys that have not been seen for a whole window, so that the records do not grow without bound.
+	// When summarizing, such a key's count of suppressed entries has already been summarized; otherwise, it is lost.
+	if now.Sub(d.lastSweep) >= window {
+		for k, record := range d.records {
+			if k != key && now.Sub(record.start) >= 2*window {
+				delete(d.records, k)
+			}
+		}
+		d.lastSweep = now
+	}
+
+	record, okay := d.records[key]
+	if okay && now.Sub(record.start) < window {
+		record.suppressed++
+		d.deferSummary(record.start.Add(window))
+		return 0, true, summaries
+	}
+	if okay {
+		repeated = record.suppressed
+	}
+	d.sequence++
+	d.records[key] = &dedupRecord{start: now, sequence: d.sequence, level: entry.Level, message: entry.Message, caller: entry.Caller}
+	return repeated, false, summaries
+}
+
+// deferSummary makes sure that the next check at or after the given time looks for summaries.
+func (d *deduplicator) deferSummary(closes time.Time) {
+	if d.nextSummary.IsZero() || closes.Before(d.nextSummary) {
+		d.nextSummary = closes
+	}
+}
+
+// dedup returns true if the entry is a duplicate that should be suppressed; otherwise, it returns the number of
+// duplicates that were suppressed since the last one was written (see `DedupWindow`).
+// It also returns a summary for each other key whose window has closed with suppressed entries, unless `DisableNewline`
+// is set (in which case there is no way to write more than one entry at a time).
+//
+// Each output (the formatter itself and each `Tee`) has its own deduplicator, so that they do not suppress each other's entries.
+func (f *Formatter) dedup(d *deduplicator, entry *logrus.Entry) (repeated int, suppressed bool, summaries []dedupSummary) {
+	if f.DedupWindow <= 0 {
+		return 0, false, nil
+	}
+	keyFunc := f.DedupKeyFunc
+	if keyFunc == nil {
+		keyFunc = DefaultDedupKey
+	}
+	return d.check(keyFunc(entry), entry, f.DedupWindow, f.now(), !f.DisableNewline)
+}
+
+// summaryEntries builds a map entry for each summary: the summarized entry's level and message, with the count as `RepeatedKey`.
+//
+// The summary is not an entry that was logged, so it has no fields or context (and so no trace); its time is the current time.
+// Its source location (see `ReportCaller`) is that of the summarized entry, if logrus reported one.
+func (f *Formatter) summaryEntries(entry *logrus.Entry, summaries []dedupSummary) []map[string]interface{} {
+	var mapEntries []map[string]interface{}
+	for _, summary := range summaries {
+		summaryEntry := logrus.NewEntry(entry.Logger)
+		summaryEntry.Level = summary.level
+		summaryEntry.Message = summary.message
+		summaryEntry.Time = f.now()
+		summaryEntry.Caller = summary.caller
+		mapEntry, err := f.buildEntry(summaryEntry, summary.repeated)
+		if err != nil {
+			continue
+		}
+		if summary.caller == nil {
+			// Otherwise, the source location would be that of the entry being formatted.
+			delete(mapEntry, SourceLocationKey)
+		}
+		mapEntries = append(mapEntries, mapEntry)
+	}
+	return mapEntries
+}
@@ -0,0 +1,265 @@
+package gcfstructuredlogformatter
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock that only moves when it is told to.
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestFormatWithDedup(t *testing.T) {
+	logger := logrus.New()
+
+	// format formats an entry with the given level, message, and fields.
+	format := func(t *testing.T, formatter *Formatter, level logrus.Level, message string, fields logrus.Fields) string {
+		e := logger.WithFields(fields)
+		e.Message = message
+		e.Level = level
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		return string(result)
+	}
+
+	t.Run("Suppression", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		assert.Equal(t, `{"message":"boom","severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		for i := 0; i < 3; i++ {
+			clock.Advance(time.Second)
+			assert.Equal(t, "", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		}
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","repeated":3,"severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		assert.Equal(t, "", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","repeated":1,"severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+	})
+
+	t.Run("Window Boundary", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		clock.Advance(time.Minute - time.Nanosecond)
+		assert.Empty(t, format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		clock.Advance(time.Nanosecond)
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "boom", nil))
+	})
+
+	t.Run("Different Entries", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "bang", nil))
+		assert.NotEmpty(t, format(t, formatter, logrus.WarnLevel, "boom", nil))
+		assert.Empty(t, format(t, formatter, logrus.ErrorLevel, "boom", logrus.Fields{"id": "123"}))
+	})
+
+	t.Run("Key Function", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute), WithDedupKeyFunc(func(entry *logrus.Entry) string {
+			code, _ := entry.Data["code"].(string)
+			return code
+		}))
+		formatter.nowFunc = clock.Now
+
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "first", logrus.Fields{"code": "A"}))
+		assert.Empty(t, format(t, formatter, logrus.ErrorLevel, "second", logrus.Fields{"code": "A"}))
+		assert.NotEmpty(t, format(t, formatter, logrus.ErrorLevel, "first", logrus.Fields{"code": "B"}))
+	})
+
+	t.Run("Ordered Keys", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute), WithOrderedKeys(true))
+		formatter.nowFunc = clock.Now
+
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"severity":"Error","message":"boom","repeated":1,"key":"value"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", logrus.Fields{"key": "value"}))
+	})
+
+	t.Run("Repeated Field", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		fields := logrus.Fields{RepeatedKey: "user-value"}
+		assert.Equal(t, `{"message":"boom","repeated":"user-value","severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", fields))
+		format(t, formatter, logrus.ErrorLevel, "boom", fields)
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"fields.repeated":"user-value","message":"boom","repeated":1,"severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", fields))
+
+		formatter.ReservedKeyPolicy = ReservedKeyDrop
+		format(t, formatter, logrus.ErrorLevel, "boom", fields)
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","repeated":1,"severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", fields))
+	})
+
+	t.Run("Summary", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.WarnLevel, "bang", nil)
+		format(t, formatter, logrus.WarnLevel, "bang", nil)
+
+		// The windows are still open.
+		clock.Advance(time.Second)
+		assert.Equal(t, `{"message":"other","severity":"Info"}`+"\n", format(t, formatter, logrus.InfoLevel, "other", nil))
+
+		// The windows have closed; the summaries come first, in the order of the windows.
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","repeated":2,"severity":"Error","time":"2020-01-02T03:05:06Z"}`+"\n"+`{"message":"bang","repeated":1,"severity":"Warning","time":"2020-01-02T03:05:06Z"}`+"\n"+`{"message":"another","severity":"Info"}`+"\n", format(t, formatter, logrus.InfoLevel, "another", nil))
+
+		// Each summary is only written once.
+		assert.Equal(t, `{"message":"boom","severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		assert.Equal(t, `{"message":"bang","severity":"Warning"}`+"\n", format(t, formatter, logrus.WarnLevel, "bang", nil))
+	})
+
+	t.Run("Summary Caller", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute), WithReportCaller(true), WithDisableTimestamp(true))
+		formatter.nowFunc = clock.Now
+
+		e := logger.WithFields(nil)
+		e.Message = "boom"
+		e.Level = logrus.ErrorLevel
+		e.Caller = &runtime.Frame{File: "file.go", Line: 1, Function: "function"}
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		_, err = formatter.Format(e)
+		require.Nil(t, err)
+
+		clock.Advance(time.Minute)
+		e = logger.WithFields(nil)
+		e.Message = "other"
+		e.Level = logrus.InfoLevel
+		e.Caller = &runtime.Frame{File: "other.go", Line: 2, Function: "other"}
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"logging.googleapis.com/sourceLocation":{"file":"file.go","line":"1","function":"function"},"message":"boom","repeated":1,"severity":"Error"}`+"\n"+`{"logging.googleapis.com/sourceLocation":{"file":"other.go","line":"2","function":"other"},"message":"other","severity":"Info"}`+"\n", string(result))
+	})
+
+	t.Run("Summary for Suppressed Entry", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		clock.Advance(30 * time.Second)
+		format(t, formatter, logrus.InfoLevel, "other", nil)
+		clock.Advance(40 * time.Second)
+
+		// "other" is suppressed, but the summary for "boom" is still written.
+		assert.Equal(t, `{"message":"boom","repeated":1,"severity":"Error","time":"2020-01-02T03:05:15Z"}`+"\n", format(t, formatter, logrus.InfoLevel, "other", nil))
+	})
+
+	t.Run("Summary without Newline", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute), WithDisableNewline(true))
+		formatter.nowFunc = clock.Now
+
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		format(t, formatter, logrus.ErrorLevel, "boom", nil)
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"other","severity":"Info"}`, format(t, formatter, logrus.InfoLevel, "other", nil))
+		assert.Equal(t, `{"message":"boom","repeated":1,"severity":"Error"}`, format(t, formatter, logrus.ErrorLevel, "boom", nil))
+	})
+
+	t.Run("Dropped Entries Not Counted", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute), WithMinLevel(logrus.InfoLevel))
+		formatter.nowFunc = clock.Now
+
+		assert.Empty(t, format(t, formatter, logrus.DebugLevel, "boom", nil))
+		assert.NotEmpty(t, format(t, formatter, logrus.InfoLevel, "boom", nil))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		formatter := New()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, `{"message":"boom","severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+		}
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		var mutex sync.Mutex
+		written := 0
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					if format(t, formatter, logrus.ErrorLevel, "boom", nil) != "" {
+						mutex.Lock()
+						written++
+						mutex.Unlock()
+					}
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, written)
+
+		clock.Advance(time.Minute)
+		assert.Equal(t, `{"message":"boom","repeated":999,"severity":"Error"}`+"\n", format(t, formatter, logrus.ErrorLevel, "boom", nil))
+	})
+
+	t.Run("Forgotten Keys", func(t *testing.T) {
+		clock := newFakeClock()
+		formatter := New(WithDedup(time.Minute))
+		formatter.nowFunc = clock.Now
+
+		format(t, formatter, logrus.ErrorLevel, "first", nil)
+		format(t, formatter, logrus.ErrorLevel, "second", nil)
+		clock.Advance(2 * time.Minute)
+		format(t, formatter, logrus.ErrorLevel, "third", nil)
+		assert.Len(t, formatter.deduplicator.records, 1)
+	})
+}
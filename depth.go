@@ -0,0 +1,96 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"reflect"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TooDeepValue replaces a map or slice that is nested deeper than `MaxNestingDepth`.
+const TooDeepValue = "[TOO DEEP]"
+
+// limitDepth returns the value with any map or slice nested deeper than `MaxNestingDepth` replaced with `TooDeepValue`.
+//
+// The depth is the depth of the value itself: a logrus field whose value is a map is at depth 1, a map inside of
+// that map is at depth 2, and so on.
+// Structs and other slices and maps are converted into their JSON form first so that they can be walked; a value with
+// its own JSON or text encoding (such as a `time.Time`) is left as-is.
+// The original value is never modified.
+func (f *Formatter) limitDepth(value interface{}, depth int) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return f.limitMapDepth(value, depth)
+	case logrus.Fields:
+		return f.limitMapDepth(value, depth)
+	case map[string]string:
+		if depth > f.MaxNestingDepth {
+			return TooDeepValue
+		}
+		return value
+	case []interface{}:
+		if depth > f.MaxNestingDepth {
+			return TooDeepValue
+		}
+		output := make([]interface{}, len(value))
+		for i, v := range value {
+			output[i] = f.limitDepth(v, depth+1)
+		}
+		return output
+	}
+	if generic, okay := toGeneric(value); okay {
+		return f.limitDepth(generic, depth)
+	}
+	return value
+}
+
+// limitMapDepth returns the map with its nested values limited to `MaxNestingDepth`; see `limitDepth`.
+func (f *Formatter) limitMapDepth(value map[string]interface{}, depth int) interface{} {
+	if depth > f.MaxNestingDepth {
+		return TooDeepValue
+	}
+	output := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		output[k] = f.limitDepth(v, depth+1)
+	}
+	return output
+}
+
+// toGeneric converts a struct, map, slice, or array into its JSON form as a `map[string]interface{}` or `[]interface{}`.
+//
+// If the value is anything else (or cannot be encoded), then this returns false.
+func toGeneric(value interface{}) (interface{}, bool) {
+	switch value.(type) {
+	case json.Marshaler, encoding.TextMarshaler:
+		return nil, false
+	}
+	reflectValue := reflect.Indirect(reflect.ValueOf(value))
+	switch reflectValue.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Array:
+	case reflect.Slice:
+		if reflectValue.Type().Elem().Kind() == reflect.Uint8 {
+			// A byte slice is written as a base64 string.
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, false
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var output interface{}
+	if err := decoder.Decode(&output); err != nil {
+		return nil, false
+	}
+	switch output.(type) {
+	case map[string]interface{}, []interface{}:
+		return output, true
+	}
+	return nil, false
+}
@@ -0,0 +1,84 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithMaxNestingDepth(t *testing.T) {
+	logger := logrus.New()
+	type inner struct {
+		Name  string            `json:"name"`
+		Tags  map[string]string `json:"tags"`
+		Child *inner            `json:"child,omitempty"`
+	}
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			fields:      logrus.Fields{"nested": map[string]interface{}{"level2": map[string]interface{}{"level3": map[string]interface{}{"key": "value"}}}},
+			output:      []byte(`{"message":"test","nested":{"level2":{"level3":{"key":"value"}}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Three Levels at Depth 2",
+			options:     []Option{WithMaxNestingDepth(2)},
+			fields:      logrus.Fields{"nested": map[string]interface{}{"level2": map[string]interface{}{"level3": map[string]interface{}{"key": "value"}, "scalar": 1}}},
+			output:      []byte(`{"message":"test","nested":{"level2":{"level3":"[TOO DEEP]","scalar":1}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Slices",
+			options:     []Option{WithMaxNestingDepth(2)},
+			fields:      logrus.Fields{"list": []interface{}{[]interface{}{[]interface{}{1}}, 2}, "ints": [][]int{{1, 2}}, "bytes": []byte("hi")},
+			output:      []byte(`{"bytes":"aGk=","ints":[[1,2]],"list":[["[TOO DEEP]"],2],"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Structs",
+			options:     []Option{WithMaxNestingDepth(2)},
+			fields:      logrus.Fields{"user": &inner{Name: "a", Tags: map[string]string{"k": "v"}, Child: &inner{Name: "b", Tags: map[string]string{"k": "v"}}}},
+			output:      []byte(`{"message":"test","severity":"Info","user":{"child":{"name":"b","tags":"[TOO DEEP]"},"name":"a","tags":{"k":"v"}}}` + "\n"),
+		},
+		{
+			description: "Depth 1",
+			options:     []Option{WithMaxNestingDepth(1)},
+			fields:      logrus.Fields{"nested": map[string]interface{}{"level2": map[string]string{"key": "value"}}, "scalar": "value"},
+			output:      []byte(`{"message":"test","nested":{"level2":"[TOO DEEP]"},"scalar":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Own Encoding",
+			options:     []Option{WithMaxNestingDepth(1)},
+			fields:      logrus.Fields{"time": time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)},
+			output:      []byte(`{"message":"test","severity":"Info","time":"2024-06-15T08:30:15Z"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+
+	t.Run("Original Unchanged", func(t *testing.T) {
+		nested := map[string]interface{}{"level2": map[string]interface{}{"key": "value"}}
+		e := logger.WithField("nested", nested)
+		e.Level = logrus.InfoLevel
+
+		_, err := New(WithMaxNestingDepth(1)).Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{"level2": map[string]interface{}{"key": "value"}}, nested)
+	})
+}
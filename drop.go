@@ -0,0 +1,10 @@
+package gcfstructuredlogformatter
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// DropFunc returns true if a log entry should be dropped (for example, a health check request).
+//
+// A dropped entry is formatted as an empty slice, so nothing is written (see `Formatter.Format`).
+type DropFunc func(entry *logrus.Entry) bool
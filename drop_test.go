@@ -0,0 +1,74 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithDropFunc(t *testing.T) {
+	isHealthCheck := func(entry *logrus.Entry) bool {
+		return entry.Data["path"] == "/healthz"
+	}
+
+	t.Run("Format", func(t *testing.T) {
+		rows := []struct {
+			description string
+			path        string
+			output      []byte
+		}{
+			{
+				description: "Dropped",
+				path:        "/healthz",
+				output:      []byte{},
+			},
+			{
+				description: "Kept",
+				path:        "/api",
+				output:      []byte(`{"message":"test","path":"/api","severity":"Info"}` + "\n"),
+			},
+		}
+
+		for _, row := range rows {
+			t.Run(row.description, func(t *testing.T) {
+				e := logrus.WithField("path", row.path)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+
+				formatter := New(WithDropFunc(isHealthCheck))
+				result, err := formatter.Format(e)
+				require.Nil(t, err)
+				assert.Equal(t, row.output, result)
+			})
+		}
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetFormatter(New(WithDisableTimestamp(true), WithDropFunc(isHealthCheck)))
+
+		logger.WithField("path", "/healthz").Info("request")
+		logger.WithField("path", "/api").Info("request")
+		assert.Equal(t, `{"message":"request","path":"/api","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("After Min Level", func(t *testing.T) {
+		calls := 0
+		formatter := New(WithMinLevel(logrus.InfoLevel), WithDropFunc(func(*logrus.Entry) bool {
+			calls++
+			return false
+		}))
+
+		e := logrus.NewEntry(logrus.New())
+		e.Level = logrus.DebugLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, []byte{}, result)
+		assert.Equal(t, 0, calls, "entries below the minimum level are dropped without calling the drop function")
+	})
+}
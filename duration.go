@@ -0,0 +1,25 @@
+package gcfstructuredlogformatter
+
+import (
+	"time"
+)
+
+// DurationFormat determines how `time.Duration` field values are written.
+type DurationFormat int
+
+const (
+	// DurationNanoseconds writes a duration as an integer number of nanoseconds (as `encoding/json` does).
+	// This is the default.
+	DurationNanoseconds DurationFormat = iota
+	// DurationString writes a duration as a string such as "1.5s" (see `time.Duration.String`).
+	DurationString
+)
+
+// formatDuration returns the value to write for a duration.
+func (f *Formatter) formatDuration(value time.Duration) interface{} {
+	switch f.DurationFormat {
+	case DurationString:
+		return value.String()
+	}
+	return int64(value)
+}
@@ -0,0 +1,79 @@
+package gcfstructuredlogformatter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithDurationFormat(t *testing.T) {
+	logger := logrus.New()
+	latency := 1500 * time.Millisecond
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"latency":1500000000,"message":"test","nested":{"timeout":2000000000},"pointer":1500000000,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nanoseconds",
+			options:     []Option{WithDurationFormat(DurationNanoseconds)},
+			output:      []byte(`{"latency":1500000000,"message":"test","nested":{"timeout":2000000000},"pointer":1500000000,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "String",
+			options:     []Option{WithDurationFormat(DurationString)},
+			output:      []byte(`{"latency":"1.5s","message":"test","nested":{"timeout":"2s"},"pointer":"1.5s","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(logrus.Fields{
+				"latency": latency,
+				"pointer": &latency,
+				"nested":  map[string]interface{}{"timeout": 2 * time.Second},
+			})
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithNumbers(t *testing.T) {
+	logger := logrus.New()
+	e := logger.WithFields(logrus.Fields{
+		"int":     42,
+		"int64":   int64(9007199254740993),
+		"uint64":  uint64(18446744073709551615),
+		"float":   1.5,
+		"number":  json.Number("12345678901234567890"),
+		"decimal": json.Number("0.10"),
+	})
+	e.Message = "test"
+	e.Level = logrus.InfoLevel
+
+	for _, options := range [][]Option{nil, {WithOrderedKeys(true)}, {WithDurationFormat(DurationString)}} {
+		formatter := New(options...)
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Contains(t, string(result), `"int":42`)
+		assert.Contains(t, string(result), `"int64":9007199254740993`)
+		assert.Contains(t, string(result), `"uint64":18446744073709551615`)
+		assert.Contains(t, string(result), `"float":1.5`)
+		assert.Contains(t, string(result), `"number":12345678901234567890`)
+		assert.Contains(t, string(result), `"decimal":0.10`)
+	}
+}
@@ -0,0 +1,204 @@
+package gcfstructuredlogformatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// reservedKeyOrder is the default order in which the formatter's own keys are written when `OrderedKeys` is set.
+var reservedKeyOrder = []string{
+	SeverityKey,
+	MessageKey,
+	MessageTemplateKey,
+	ComponentKey,
+	TimestampKey,
+	InsertIDKey,
+	TraceKey,
+	SpanKey,
+	TraceSampledKey,
+	FlatTraceIDKey,
+	FlatSpanIDKey,
+	TraceFlagsKey,
+	TraceStateKey,
+	SourceLocationKey,
+	OperationKey,
+	HTTPRequestKey,
+	ErrorReportingTypeKey,
+	ServiceContextKey,
+	StackTraceKey,
+	RepeatedKey,
+	RetentionKey,
+	ResourceKey,
+	LabelsKey,
+	PlainLabelsKey,
+}
+
+// reservedKeys returns the formatter's own keys, in the order in which they are written when `OrderedKeys` is set.
+//
+// This takes any renamed keys into account.
+// With `LabelsFlat`, the flat label keys (see `FlatLabelPrefix`) in the map entry follow `LabelsKey`, in sorted order;
+// the map entry may be nil.
+// A logrus field cannot have such a key (see `addField`) unless `ReservedKeyOverwrite` lets it take a label's place,
+// so these are the labels.
+func (f *Formatter) reservedKeys(mapEntry map[string]interface{}) []string {
+	keys := make([]string, 0, len(reservedKeyOrder))
+	for _, key := range reservedKeyOrder {
+		switch key {
+		case SeverityKey, TimestampKey:
+			key = f.fieldKey(key)
+		case MessageKey:
+			key = f.messageKey()
+			if key == "" {
+				continue
+			}
+		case TraceKey:
+			key = f.traceKey()
+		case SpanKey:
+			key = f.spanKey()
+		case FlatTraceIDKey, FlatSpanIDKey:
+			if !f.FlatTraceFields {
+				continue
+			}
+		case TraceFlagsKey, TraceStateKey:
+			if !f.TraceFlagsAndState {
+				continue
+			}
+		case ComponentKey:
+			if f.ComponentField == "" {
+				continue
+			}
+		case RetentionKey:
+			key = f.retentionKey()
+		case RepeatedKey:
+			if f.DedupWindow <= 0 {
+				continue
+			}
+		case PlainLabelsKey:
+			if f.LabelsMode != LabelsPlain && f.LabelsMode != LabelsBoth {
+				continue
+			}
+		}
+		keys = append(keys, key)
+		if key == LabelsKey && f.LabelsMode == LabelsFlat {
+			keys = append(keys, flatLabelKeysIn(mapEntry)...)
+		}
+	}
+	return keys
+}
+
+// flatLabelKeysIn returns the keys in the map entry that have the `FlatLabelPrefix`, in sorted order.
+func flatLabelKeysIn(mapEntry map[string]interface{}) []string {
+	var keys []string
+	for key := range mapEntry {
+		if strings.HasPrefix(key, FlatLabelPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// containsKey returns true if the key is in the list of keys.
+func containsKey(keys []string, key string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeOrdered writes the map entry to the buffer with the reserved keys first (in the given order),
+// followed by the remaining keys in sorted order.
+//
+// Like `json.Encoder.Encode`, this writes a trailing newline.
+func encodeOrdered(buffer *encodeBuffer, mapEntry map[string]interface{}, reservedKeys []string) error {
+	keys := make([]string, 0, len(mapEntry))
+	for _, key := range reservedKeys {
+		if _, okay := mapEntry[key]; okay {
+			keys = append(keys, key)
+		}
+	}
+	reservedCount := len(keys)
+	for key := range mapEntry {
+		if !containsKey(reservedKeys, key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys[reservedCount:])
+
+	buffer.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buffer.WriteByte(',')
+		}
+		if err := encodeValue(buffer, key); err != nil {
+			return err
+		}
+		buffer.WriteByte(':')
+		if err := encodeValue(buffer, mapEntry[key]); err != nil {
+			return err
+		}
+	}
+	buffer.WriteString("}\n")
+	return nil
+}
+
+// encodeValue writes a single JSON value to the buffer, without a trailing newline.
+func encodeValue(buffer *encodeBuffer, value interface{}) error {
+	if err := buffer.encoder.Encode(value); err != nil {
+		return err
+	}
+	buffer.Truncate(buffer.Len() - 1)
+	return nil
+}
+
+// replaceUnserializable replaces each value in the map entry that cannot be encoded with a string
+// such as "<unserializable: chan int>", so that one bad field does not lose the whole entry.
+//
+// The fields nested under `DataKey` are checked individually, since the formatter owns that map.
+// This returns true if anything was replaced.
+func (f *Formatter) replaceUnserializable(buffer *encodeBuffer, mapEntry map[string]interface{}) bool {
+	replaced := false
+	for key, value := range mapEntry {
+		buffer.Reset()
+		if err := encodeValue(buffer, value); err == nil {
+			continue
+		}
+		if data, okay := value.(map[string]interface{}); okay && f.DataKey != "" && key == f.DataKey {
+			if f.replaceUnserializable(buffer, data) {
+				replaced = true
+				continue
+			}
+		}
+		mapEntry[key] = fmt.Sprintf("<unserializable: %T>", value)
+		replaced = true
+	}
+	return replaced
+}
+
+// findUnserializableKey returns the key of the first value (in sorted order) in the map entry that cannot be encoded.
+//
+// A value nested in a map is named by joining the keys with "." (for example, "DATA_KEY.KEY").
+// If every value can be encoded, then this returns an empty string.
+func findUnserializableKey(buffer *encodeBuffer, mapEntry map[string]interface{}) string {
+	keys := make([]string, 0, len(mapEntry))
+	for key := range mapEntry {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buffer.Reset()
+		if err := encodeValue(buffer, mapEntry[key]); err == nil {
+			continue
+		}
+		if data, okay := mapEntry[key].(map[string]interface{}); okay {
+			if nestedKey := findUnserializableKey(buffer, data); nestedKey != "" {
+				return key + "." + nestedKey
+			}
+		}
+		return key
+	}
+	return ""
+}
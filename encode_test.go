@@ -0,0 +1,196 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithOrderedKeys(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Empty Entry",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"severity":"Info","message":""}` + "\n"),
+		},
+		{
+			description: "Full Entry",
+			options:     []Option{WithLabels(map[string]string{"key": "value"})},
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001"})
+				e := logger.WithContext(ctx).WithFields(logrus.Fields{"zebra": 1, "apple": "<b>", "mango": map[string]interface{}{"b": 2, "a": 1}})
+				e.Message = "test"
+				e.Level = logrus.WarnLevel
+				e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+				return e
+			}(),
+			output: []byte(`{"severity":"Warning","message":"test","time":"2024-06-15T08:30:15Z","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":false,"logging.googleapis.com/labels":{"key":"value"},"apple":"<b>","mango":{"a":1,"b":2},"zebra":1}` + "\n"),
+		},
+		{
+			description: "Every Reserved Key",
+			options: []Option{
+				WithLabels(map[string]string{"key": "value"}),
+				WithLabelsMode(LabelsBoth),
+				WithInsertIDFunc(func(*logrus.Entry) string { return "1" }),
+				WithReportCaller(true),
+				WithErrorReporting("service", "1.0.0"),
+				WithFlatTraceFields(true),
+				WithResource("global", nil),
+			},
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Sampled: true})
+				ctx = context.WithValue(ctx, ContextKeyOperation, Operation{ID: "op"})
+				ctx = context.WithValue(ctx, ContextKeyHTTPRequest, HTTPRequest{Status: 500})
+				e := logger.WithContext(ctx).WithFields(logrus.Fields{"b": 2, "a": 1, MessageTemplateField: "template"})
+				e.Message = "test"
+				e.Level = logrus.ErrorLevel
+				e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+				e.Caller = &runtime.Frame{File: "file.go", Line: 1, Function: "function"}
+				return e
+			}(),
+			output: []byte(`{"severity":"Error","message":"test","message_template":"template","time":"2024-06-15T08:30:15Z","logging.googleapis.com/insertId":"1","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace_sampled":true,"trace_id":"105445aa7843bc8bf206b12000100000","span_id":"0000000000000001","logging.googleapis.com/sourceLocation":{"file":"file.go","line":"1","function":"function"},"logging.googleapis.com/operation":{"id":"op"},"httpRequest":{"status":500},"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent","serviceContext":{"service":"service","version":"1.0.0"},"resource":{"type":"global"},"logging.googleapis.com/labels":{"key":"value"},"labels":{"key":"value"},"a":1,"b":2}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(append(row.options, WithOrderedKeys(true))...)
+			for i := 0; i < 10; i++ {
+				result, err := formatter.Format(row.input)
+				require.Nil(t, err)
+				require.Equal(t, string(row.output), string(result))
+			}
+		})
+	}
+}
+
+func TestFormatWithUnserializableValues(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"channel":"<unserializable: chan int>","function":"<unserializable: func()>","key":"value","message":"test","nested":"<unserializable: map[string]interface {}>","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"test","channel":"<unserializable: chan int>","function":"<unserializable: func()>","key":"value","nested":"<unserializable: map[string]interface {}>"}` + "\n"),
+		},
+		{
+			description: "Data Key",
+			options:     []Option{WithDataKey("data")},
+			output:      []byte(`{"data":{"channel":"<unserializable: chan int>","function":"<unserializable: func()>","key":"value","nested":"<unserializable: map[string]interface {}>"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			// logrus's `WithFields` refuses functions, so set the data directly.
+			e := logrus.NewEntry(logger)
+			e.Data = logrus.Fields{
+				"key":      "value",
+				"channel":  make(chan int),
+				"function": func() {},
+				"nested":   map[string]interface{}{"channel": make(chan int)},
+			}
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			require.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+// errFlaky is the error returned by `flakyValue`.
+var errFlaky = errors.New("flaky failure")
+
+// flakyValue fails to marshal every other time, starting with the first.
+//
+// This gets past `replaceUnserializable`, which only replaces values that fail on their own.
+type flakyValue struct {
+	calls *int
+}
+
+func (v flakyValue) MarshalJSON() ([]byte, error) {
+	*v.calls++
+	if *v.calls%2 == 1 {
+		return nil, errFlaky
+	}
+	return []byte(`"flaky"`), nil
+}
+
+func TestFormatMarshalError(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		message     string
+	}{
+		{
+			description: "Field",
+			message:     `gcfstructuredlogformatter: failed to marshal entry (level=warning, field="flaky"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure`,
+		},
+		{
+			description: "Data Key",
+			options:     []Option{WithDataKey("data")},
+			message:     `gcfstructuredlogformatter: failed to marshal entry (level=warning, field="data"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure`,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			var calls int
+			e := logger.WithFields(logrus.Fields{"key": "value", "flaky": flakyValue{calls: &calls}})
+			e.Message = "test"
+			e.Level = logrus.WarnLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.NotNil(t, err)
+			assert.Nil(t, result)
+			assert.Equal(t, row.message, err.Error())
+			assert.True(t, errors.Is(err, errFlaky))
+			assert.NotNil(t, errors.Unwrap(err))
+		})
+	}
+}
+
+func TestMarshalError(t *testing.T) {
+	var calls int
+	_, err := New().Marshal(map[string]interface{}{"flaky": flakyValue{calls: &calls}})
+	require.NotNil(t, err)
+	assert.Equal(t, `gcfstructuredlogformatter: failed to marshal entry (field="flaky"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure`, err.Error())
+	assert.True(t, errors.Is(err, errFlaky))
+}
+
+func TestFindUnserializableKey(t *testing.T) {
+	buffer := getBuffer(nil)
+	defer putBuffer(buffer)
+
+	assert.Equal(t, "", findUnserializableKey(buffer, map[string]interface{}{"key": "value"}))
+	assert.Equal(t, "a", findUnserializableKey(buffer, map[string]interface{}{"b": make(chan int), "a": make(chan int)}))
+	assert.Equal(t, "data.channel", findUnserializableKey(buffer, map[string]interface{}{"key": "value", "data": map[string]interface{}{"key": "value", "channel": make(chan int)}}))
+}
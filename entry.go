@@ -0,0 +1,59 @@
+package gcfstructuredlogformatter
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Entry is a log entry as written by the formatter.
+//
+// This is meant for tests that need to assert on the emitted logs; use `ParseEntry` to decode a formatted line.
+// It assumes the default keys (for example, a custom `MessageKey` ends up in `Fields`).
+type Entry struct {
+	Severity       string            `json:"severity"`                                        // The severity; for example, "Info".
+	Message        string            `json:"message"`                                         // The message.
+	Timestamp      time.Time         `json:"time"`                                            // The timestamp; this is zero if the timestamp was omitted.
+	InsertID       string            `json:"logging.googleapis.com/insertId,omitempty"`       // The insert ID.
+	Trace          string            `json:"logging.googleapis.com/trace,omitempty"`          // The trace.
+	SpanID         string            `json:"logging.googleapis.com/spanId,omitempty"`         // The span ID.
+	TraceSampled   bool              `json:"logging.googleapis.com/trace_sampled,omitempty"`  // Whether or not the trace was sampled.
+	Labels         map[string]string `json:"logging.googleapis.com/labels,omitempty"`         // The labels.
+	SourceLocation *SourceLocation   `json:"logging.googleapis.com/sourceLocation,omitempty"` // The source location.
+	Operation      *Operation        `json:"logging.googleapis.com/operation,omitempty"`      // The operation.
+
+	Fields map[string]interface{} `json:"-"` // These are all of the other keys, such as the logrus fields.
+}
+
+// entryKeys are the keys that are decoded into the named fields of an `Entry`.
+var entryKeys = []string{
+	SeverityKey,
+	MessageKey,
+	TimestampKey,
+	InsertIDKey,
+	TraceKey,
+	SpanKey,
+	TraceSampledKey,
+	LabelsKey,
+	SourceLocationKey,
+	OperationKey,
+}
+
+// ParseEntry decodes a log entry that was written by the formatter.
+func ParseEntry(contents []byte) (*Entry, error) {
+	var entry Entry
+	if err := json.Unmarshal(contents, &entry); err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return nil, err
+	}
+	for _, key := range entryKeys {
+		delete(fields, key)
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return &entry, nil
+}
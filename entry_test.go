@@ -0,0 +1,81 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEntry(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      *Entry
+	}{
+		{
+			description: "Minimal",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: &Entry{
+				Severity: "Info",
+				Message:  "test",
+			},
+		},
+		{
+			description: "Full",
+			options:     []Option{WithLabels(map[string]string{"key": "value"}), WithInsertIDFunc(InsertIDFromField("id")), WithReportCaller(true)},
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001", Sampled: true})
+				ctx = context.WithValue(ctx, ContextKeyOperation, Operation{ID: "op", First: true})
+				e := logger.WithContext(ctx).WithFields(logrus.Fields{"id": "abc", "prop": "value", "count": 2})
+				e.Message = "test"
+				e.Level = logrus.WarnLevel
+				e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+				e.Caller = &runtime.Frame{File: "main.go", Line: 42, Function: "main.main"}
+				return e
+			}(),
+			output: &Entry{
+				Severity:       "Warning",
+				Message:        "test",
+				Timestamp:      time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC),
+				InsertID:       "abc",
+				Trace:          "105445aa7843bc8bf206b12000100000",
+				SpanID:         "0000000000000001",
+				TraceSampled:   true,
+				Labels:         map[string]string{"key": "value"},
+				SourceLocation: &SourceLocation{File: "main.go", Line: 42, Function: "main.main"},
+				Operation:      &Operation{ID: "op", First: true},
+				Fields:         map[string]interface{}{"id": "abc", "prop": "value", "count": float64(2)},
+			},
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+
+			entry, err := ParseEntry(result)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, entry)
+		})
+	}
+}
+
+func TestParseEntryInvalid(t *testing.T) {
+	entry, err := ParseEntry([]byte(`{"severity":`))
+	assert.NotNil(t, err)
+	assert.Nil(t, entry)
+}
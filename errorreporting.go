@@ -0,0 +1,20 @@
+package gcfstructuredlogformatter
+
+// ErrorReportingType is the "@type" value that marks a log entry as an error event for Google Cloud Error Reporting.
+const ErrorReportingType = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// ErrorReporting is the configuration for Google Cloud Error Reporting.
+//
+// When set on the formatter, entries with a severity of Error or worse are formatted so that Error Reporting picks them up.
+//
+// See: https://cloud.google.com/error-reporting/docs/formatting-error-messages
+type ErrorReporting struct {
+	Service string // The name of the service; this is required by Error Reporting.
+	Version string // The version of the service; this is optional.
+}
+
+// serviceContext is the JSON representation of the Error Reporting service context.
+type serviceContext struct {
+	Service string `json:"service"`
+	Version string `json:"version,omitempty"`
+}
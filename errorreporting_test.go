@@ -0,0 +1,56 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithErrorReporting(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		level       logrus.Level
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			level:       logrus.ErrorLevel,
+			output:      []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Warning",
+			options:     []Option{WithErrorReporting("my-service", "1.0.0")},
+			level:       logrus.WarnLevel,
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Error",
+			options:     []Option{WithErrorReporting("my-service", "1.0.0")},
+			level:       logrus.ErrorLevel,
+			output:      []byte(`{"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent","message":"test","serviceContext":{"service":"my-service","version":"1.0.0"},"severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Fatal without Version",
+			options:     []Option{WithErrorReporting("my-service", "")},
+			level:       logrus.FatalLevel,
+			output:      []byte(`{"@type":"type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent","message":"test","serviceContext":{"service":"my-service"},"severity":"Alert"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
@@ -0,0 +1,24 @@
+package gcfstructuredlogformatter
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// errorString returns an error value as its message; any other value is returned as-is.
+//
+// Most error types have no exported fields, so `encoding/json` would write them as "{}".
+// An error that implements `json.Marshaler` is left alone, as is a nil pointer (which is written as null).
+func errorString(value interface{}) interface{} {
+	err, okay := value.(error)
+	if !okay {
+		return value
+	}
+	if _, okay := value.(json.Marshaler); okay {
+		return value
+	}
+	if v := reflect.ValueOf(value); v.Kind() == reflect.Pointer && v.IsNil() {
+		return value
+	}
+	return err.Error()
+}
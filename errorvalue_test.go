@@ -0,0 +1,86 @@
+package gcfstructuredlogformatter
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// opaqueError is an error with no exported fields.
+type opaqueError struct {
+	code int
+}
+
+func (e *opaqueError) Error() string {
+	return fmt.Sprintf("failed with code %d", e.code)
+}
+
+// jsonError is an error that marshals itself.
+type jsonError struct{}
+
+func (jsonError) Error() string {
+	return "json error"
+}
+
+func (jsonError) MarshalJSON() ([]byte, error) {
+	return []byte(`{"kind":"json"}`), nil
+}
+
+func TestFormatWithErrorValues(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Custom Error",
+			fields:      logrus.Fields{"cause": &opaqueError{code: 7}},
+			output:      []byte(`{"cause":"failed with code 7","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Error Key",
+			fields:      logrus.Fields{logrus.ErrorKey: errors.New("boom")},
+			output:      []byte(`{"error":"boom","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Wrapped Error",
+			fields:      logrus.Fields{"cause": fmt.Errorf("wrapped: %w", &opaqueError{code: 7})},
+			output:      []byte(`{"cause":"wrapped: failed with code 7","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "JSON Marshaler",
+			fields:      logrus.Fields{"cause": jsonError{}},
+			output:      []byte(`{"cause":{"kind":"json"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nil Pointer",
+			fields:      logrus.Fields{"cause": (*opaqueError)(nil)},
+			output:      []byte(`{"cause":null,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Truncation",
+			options:     []Option{WithMaxFieldBytes(4)},
+			fields:      logrus.Fields{"cause": &opaqueError{code: 7}},
+			output:      []byte(`{"cause":"fail…(truncated)","message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// FormatErrorKey is the key for the error in the line that is written by `MarshalFallback`.
+const FormatErrorKey = "_format_error"
+
+// fallbackLine returns a minimal entry for a logrus entry that could not be built or marshaled: an "Error" severity,
+// the original message, and the error (as `FormatErrorKey`).
+//
+// `replaceUnserializable` already replaces a field value that cannot be marshaled on its own, so this is mostly reached
+// by an error from `BuildEntry` (such as from `ValueMarshaler`, `ProtoJSON`, or `ReservedKeyError`).
+// The message is always written (even with `OmitMessage`), and both strings are sanitized like any other message,
+// so `RedactPatterns` and `MaxFieldBytes` still apply.
+// This only has strings, so it always marshals.
+func (f *Formatter) fallbackLine(entry *logrus.Entry, err error) []byte {
+	messageKey := f.MessageKey
+	if messageKey == "" {
+		messageKey = MessageKey
+	}
+	mapEntry := map[string]string{
+		f.fieldKey(SeverityKey): f.severityString(logging.Error),
+		f.fieldKey(messageKey):  f.sanitizeString(f.formatMultiline(entry.Message)),
+		FormatErrorKey:          f.sanitizeString(err.Error()),
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(f.EscapeHTML)
+	_ = encoder.Encode(mapEntry)
+	if f.DisableNewline {
+		buffer.Truncate(buffer.Len() - 1)
+	}
+	return buffer.Bytes()
+}
@@ -0,0 +1,105 @@
+package gcfstructuredlogformatter
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithMarshalFallback(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"_format_error":"gcfstructuredlogformatter: failed to marshal entry (level=info, field=\"flaky\"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure","message":"test \"quoted\"","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Renamed Keys",
+			options:     []Option{WithFieldMap(map[string]string{SeverityKey: "level", MessageKey: "msg"}), WithSeverityStringer(UppercaseSeverity)},
+			output:      []byte(`{"_format_error":"gcfstructuredlogformatter: failed to marshal entry (level=info, field=\"flaky\"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure","level":"ERROR","msg":"test \"quoted\""}` + "\n"),
+		},
+		{
+			description: "Disable Newline",
+			options:     []Option{WithDisableNewline(true)},
+			output:      []byte(`{"_format_error":"gcfstructuredlogformatter: failed to marshal entry (level=info, field=\"flaky\"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure","message":"test \"quoted\"","severity":"Error"}`),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			var calls int
+			e := logger.WithFields(logrus.Fields{"key": "value", "flaky": flakyValue{calls: &calls}})
+			e.Message = `test "quoted"`
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append([]Option{WithMarshalFallback(true)}, row.options...)...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+
+	t.Run("Redaction", func(t *testing.T) {
+		var calls int
+		e := logger.WithField("flaky", flakyValue{calls: &calls})
+		e.Message = "card 4111-1111-1111-1111\nline 2"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(
+			WithMarshalFallback(true),
+			WithValueRedaction(regexp.MustCompile(`\d{4}-\d{4}-\d{4}-\d{4}`)),
+			WithMultilineMode(MultilineEscape),
+		)
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.NotContains(t, string(result), "4111")
+		assert.Equal(t, `{"_format_error":"gcfstructuredlogformatter: failed to marshal entry (level=info, field=\"flaky\"): json: error calling MarshalJSON for type *gcfstructuredlogformatter.flakyValue: flaky failure","message":"card [REDACTED]\\nline 2","severity":"Error"}`+"\n", string(result))
+	})
+
+	t.Run("Build Error", func(t *testing.T) {
+		e := logger.WithField("key", "value")
+		e.Message = "test <tag>"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithMarshalFallback(true), WithValueMarshaler(func(key string, value interface{}) (interface{}, error) {
+			return nil, errors.New("bad value")
+		}))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"_format_error":"could not marshal field \"key\": bad value","message":"test <tag>","severity":"Error"}`+"\n", string(result))
+
+		formatter.MarshalFallback = false
+		_, err = formatter.Format(e)
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Omitted Message", func(t *testing.T) {
+		var calls int
+		e := logger.WithField("flaky", flakyValue{calls: &calls})
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		result, err := New(WithMarshalFallback(true), WithMessageKey("msg"), WithOmitMessage(true)).Format(e)
+		require.Nil(t, err)
+		assert.Contains(t, string(result), `"msg":"test"`)
+	})
+
+	t.Run("Valid Line", func(t *testing.T) {
+		var calls int
+		e := logger.WithField("flaky", flakyValue{calls: &calls})
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		result, err := New(WithMarshalFallback(true)).Format(e)
+		require.Nil(t, err)
+		assert.Nil(t, ValidateLine(result))
+	})
+}
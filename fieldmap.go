@@ -0,0 +1,20 @@
+package gcfstructuredlogformatter
+
+// fieldKey returns the key that is written for one of the formatter's own keys, taking `FieldMap` into account.
+func (f *Formatter) fieldKey(key string) string {
+	if mapped := f.FieldMap[key]; mapped != "" {
+		return mapped
+	}
+	return key
+}
+
+// messageKey returns the key that is written for the message; if empty, then the message is omitted (see `OmitMessage`).
+func (f *Formatter) messageKey() string {
+	if f.OmitMessage {
+		return ""
+	}
+	if f.MessageKey == "" {
+		return f.fieldKey(MessageKey)
+	}
+	return f.fieldKey(f.MessageKey)
+}
@@ -0,0 +1,74 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithFieldMap(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		time        time.Time
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Message and Severity",
+			options:     []Option{WithFieldMap(map[string]string{MessageKey: "msg", SeverityKey: "level"})},
+			output:      []byte(`{"level":"Info","msg":"test"}` + "\n"),
+		},
+		{
+			description: "Timestamp",
+			options:     []Option{WithFieldMap(map[string]string{TimestampKey: "timestamp"})},
+			time:        time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			output:      []byte(`{"message":"test","severity":"Info","timestamp":"2020-01-02T03:04:05Z"}` + "\n"),
+		},
+		{
+			description: "Ordered Keys",
+			options:     []Option{WithOrderedKeys(true), WithFieldMap(map[string]string{MessageKey: "msg", SeverityKey: "level"})},
+			fields:      logrus.Fields{"a": "b"},
+			output:      []byte(`{"level":"Info","msg":"test","a":"b"}` + "\n"),
+		},
+		{
+			description: "Collision",
+			options:     []Option{WithFieldMap(map[string]string{MessageKey: "msg", SeverityKey: "level"})},
+			fields:      logrus.Fields{"msg": "field", "message": "other"},
+			output:      []byte(`{"fields.msg":"field","level":"Info","message":"other","msg":"test"}` + "\n"),
+		},
+		{
+			description: "Custom Message Key",
+			options:     []Option{WithMessageKey("text"), WithFieldMap(map[string]string{MessageKey: "msg"})},
+			output:      []byte(`{"severity":"Info","text":"test"}` + "\n"),
+		},
+		{
+			description: "Truncated",
+			options:     []Option{WithMaxEntryBytes(60), WithFieldMap(map[string]string{MessageKey: "msg", SeverityKey: "level"})},
+			fields:      logrus.Fields{"payload": "0123456789012345678901234567890123456789"},
+			output:      []byte(`{"level":"Info","msg":"test…(truncated)"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+			e.Time = row.time
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package gcfstructuredlogformatter
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SyncWriter is a writer that can flush the writer that it wraps.
+//
+// Use `FlushOnExit` to make sure that Fatal and Panic entries are fully written before logrus exits or panics.
+type SyncWriter struct {
+	writer    io.Writer
+	mutex     sync.Mutex
+	flushNext bool // If true, then the next write is flushed.
+}
+
+// NewSyncWriter creates a new sync writer that wraps the given writer.
+func NewSyncWriter(writer io.Writer) *SyncWriter {
+	return &SyncWriter{
+		writer: writer,
+	}
+}
+
+// Write writes to the underlying writer; if a flush was requested, then the writer is flushed afterward.
+func (w *SyncWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	n, err := w.writer.Write(p)
+	if w.flushNext {
+		w.flushNext = false
+		if syncErr := w.sync(); err == nil {
+			err = syncErr
+		}
+	}
+	return n, err
+}
+
+// Sync flushes the underlying writer.
+//
+// A writer with a `Flush() error` method (such as a `bufio.Writer`) is flushed, and a writer with a
+// `Sync() error` method (such as an `os.File`) is synced; any other writer is left as-is.
+func (w *SyncWriter) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.sync()
+}
+
+// sync flushes the underlying writer; the mutex must be held.
+func (w *SyncWriter) sync() error {
+	switch writer := w.writer.(type) {
+	case interface{ Flush() error }:
+		return writer.Flush()
+	case interface{ Sync() error }:
+		return writer.Sync()
+	}
+	return nil
+}
+
+// flushFormatter marks the sync writer to be flushed after a Fatal or Panic entry is written.
+//
+// logrus formats and writes an entry while holding its lock, so the next write is the entry that was just formatted.
+type flushFormatter struct {
+	logrus.Formatter
+	writer *SyncWriter
+}
+
+// Format formats the entry with the wrapped formatter.
+func (f *flushFormatter) Format(entry *logrus.Entry) ([]byte, error) {
+	contents, err := f.Formatter.Format(entry)
+	if err == nil && entry.Level <= logrus.FatalLevel {
+		f.writer.mutex.Lock()
+		f.writer.flushNext = true
+		f.writer.mutex.Unlock()
+	}
+	return contents, err
+}
+
+// FlushOnExit makes sure that Fatal and Panic entries are fully written before logrus exits or panics.
+//
+// This wraps the logger's output in a `SyncWriter` that is flushed after each Fatal or Panic entry is written,
+// and flushes it again before the logger's `ExitFunc` runs.
+// Call this after the logger's formatter, output, and exit function have been set; the returned writer may be
+// used to flush the output at any other time (for example, before returning from `main`).
+//
+// This relies on logrus's locking, so it must not be used with `Logger.SetNoLock`.
+func FlushOnExit(logger *logrus.Logger) *SyncWriter {
+	writer := NewSyncWriter(logger.Out)
+	logger.SetOutput(writer)
+	logger.SetFormatter(&flushFormatter{
+		Formatter: logger.Formatter,
+		writer:    writer,
+	})
+
+	exitFunc := logger.ExitFunc
+	if exitFunc == nil {
+		exitFunc = os.Exit
+	}
+	logger.ExitFunc = func(code int) {
+		_ = writer.Sync()
+		exitFunc(code)
+	}
+	return writer
+}
@@ -0,0 +1,68 @@
+package gcfstructuredlogformatter
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBufferedLogger returns a logger that writes through a large buffered writer, so that nothing reaches
+// the returned buffer until the writer is flushed.
+func newBufferedLogger() (*logrus.Logger, *bytes.Buffer) {
+	var output bytes.Buffer
+	logger := logrus.New()
+	logger.SetFormatter(New(WithDisableTimestamp(true)))
+	logger.SetOutput(bufio.NewWriterSize(&output, 64*1024))
+	return logger, &output
+}
+
+func TestFlushOnExit(t *testing.T) {
+	t.Run("Fatal", func(t *testing.T) {
+		logger, output := newBufferedLogger()
+
+		var exitCode int
+		var written string
+		logger.ExitFunc = func(code int) {
+			exitCode = code
+			written = output.String()
+		}
+		FlushOnExit(logger)
+
+		logger.Info("before")
+		assert.Equal(t, "", output.String())
+
+		logger.Fatal("fatal")
+		assert.Equal(t, 1, exitCode)
+		assert.Equal(t, `{"message":"before","severity":"Info"}`+"\n"+`{"message":"fatal","severity":"Alert"}`+"\n", written)
+	})
+	t.Run("Panic", func(t *testing.T) {
+		logger, output := newBufferedLogger()
+		FlushOnExit(logger)
+
+		logger.Info("before")
+		assert.Equal(t, "", output.String())
+
+		require.Panics(t, func() {
+			logger.Panic("panic")
+		})
+		assert.Equal(t, `{"message":"before","severity":"Info"}`+"\n"+`{"message":"panic","severity":"Emergency"}`+"\n", output.String())
+	})
+}
+
+func TestSyncWriter(t *testing.T) {
+	var output bytes.Buffer
+	buffered := bufio.NewWriter(&output)
+	writer := NewSyncWriter(buffered)
+
+	_, err := writer.Write([]byte("test\n"))
+	require.Nil(t, err)
+	assert.Equal(t, "", output.String())
+
+	err = writer.Sync()
+	require.Nil(t, err)
+	assert.Equal(t, "test\n", output.String())
+}
@@ -1,7 +1,15 @@
 package gcfstructuredlogformatter
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
 	"cloud.google.com/go/logging"
 	"github.com/sirupsen/logrus"
@@ -12,17 +20,61 @@ import (
 const (
 	// TraceKey is the key for the trace identifier.
 	TraceKey = "logging.googleapis.com/trace"
+	// TraceSampledKey is the key for whether or not the trace was sampled.
+	TraceSampledKey = "logging.googleapis.com/trace_sampled"
 	// SpanKey is the key for the span identifier.
 	SpanKey = "logging.googleapis.com/spanId"
 	// SeverityKey is the key for the severity.
 	SeverityKey = "severity"
 	// MessageKey is the key for the message.
 	MessageKey = "message"
+	// InsertIDKey is the key for the insert ID.
+	InsertIDKey = "logging.googleapis.com/insertId"
+	// ErrorReportingTypeKey is the key for the Error Reporting type.
+	ErrorReportingTypeKey = "@type"
+	// ServiceContextKey is the key for the Error Reporting service context.
+	ServiceContextKey = "serviceContext"
+	// StackTraceKey is the key for the stack trace of the error.
+	StackTraceKey = "stack_trace"
+	// TimestampKey is the key for the timestamp.
+	TimestampKey = "time"
 	// LabelsKey is the key for the labels.
 	LabelsKey = "logging.googleapis.com/labels"
+	// SourceLocationKey is the key for the source location.
+	SourceLocationKey = "logging.googleapis.com/sourceLocation"
+	// OperationKey is the key for the operation.
+	OperationKey = "logging.googleapis.com/operation"
+	// HTTPRequestKey is the key for the HTTP request.
+	HTTPRequestKey = "httpRequest"
+	// ResourceKey is the key for the monitored resource.
+	ResourceKey = "resource"
+	// MessageTemplateKey is the key for the unformatted message template (see `MessageTemplateField`).
+	MessageTemplateKey = "message_template"
+	// ComponentKey is the key for the component that wrote the entry (see `ComponentField`).
+	ComponentKey = "component"
+
+	// FlatTraceIDKey is the key for the plain trace identifier (see `FlatTraceFields`).
+	FlatTraceIDKey = "trace_id"
+	// FlatSpanIDKey is the key for the plain span identifier (see `FlatTraceFields`).
+	FlatSpanIDKey = "span_id"
+	// TraceFlagsKey is the key for the OpenTelemetry trace flags, as a hexadecimal byte (see `TraceFlagsAndState`).
+	TraceFlagsKey = "trace_flags"
+	// TraceStateKey is the key for the OpenTelemetry trace state (see `TraceFlagsAndState`).
+	TraceStateKey = "trace_state"
 )
 
+// MessageTemplateField is the logrus field for the unformatted message template.
+//
+// This is written to `MessageTemplateKey` instead of with the other fields, so that the message can be
+// grouped by its template while `entry.Message` keeps the rendered text.
+const MessageTemplateField = "_template"
+
+// ErrNilEntry is returned when formatting a nil entry.
+var ErrNilEntry = errors.New("entry is nil")
+
 // logrusToGoogleSeverityMap maps a logrus level to a Google severity.
+//
+// The severity is written using `logging.Severity.String()`, which round-trips through `logging.ParseSeverity()`.
 var logrusToGoogleSeverityMap = map[logrus.Level]logging.Severity{
 	logrus.PanicLevel: logging.Emergency,
 	logrus.FatalLevel: logging.Alert,
@@ -33,22 +85,346 @@ var logrusToGoogleSeverityMap = map[logrus.Level]logging.Severity{
 	logrus.TraceLevel: logging.Default,
 }
 
+// maximumPooledBufferSize is the largest buffer that will be returned to the pool.
+// Larger buffers are left for the garbage collector so that one huge entry does not pin memory.
+const maximumPooledBufferSize = 64 * 1024
+
+// encodeBuffer is a buffer with an encoder that writes to it.
+//
+// The buffer normally writes to its own pooled buffer, but it may be pointed at another buffer
+// (such as the one that logrus provides in `logrus.Entry.Buffer`).
+type encodeBuffer struct {
+	*bytes.Buffer               // This is the buffer that is currently being written to.
+	own           *bytes.Buffer // This is the buffer's own pooled buffer.
+	encoder       *json.Encoder // This encoder writes to the current buffer.
+}
+
+// Write writes to the current buffer; this allows the encoder to follow the current buffer.
+func (b *encodeBuffer) Write(p []byte) (int, error) {
+	return b.Buffer.Write(p)
+}
+
+// bufferPool is a pool of encode buffers for formatting entries.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buffer := &encodeBuffer{
+			own: new(bytes.Buffer),
+		}
+		buffer.encoder = json.NewEncoder(buffer)
+		return buffer
+	},
+}
+
+// getBuffer returns an encode buffer from the pool that writes to the given buffer.
+// If the given buffer is nil, then the encode buffer writes to its own buffer.
+func getBuffer(target *bytes.Buffer) *encodeBuffer {
+	buffer := bufferPool.Get().(*encodeBuffer)
+	if target == nil {
+		target = buffer.own
+	}
+	buffer.Buffer = target
+	return buffer
+}
+
+// putBuffer returns an encode buffer to the pool.
+func putBuffer(buffer *encodeBuffer) {
+	buffer.Buffer = nil
+	if buffer.own.Cap() > maximumPooledBufferSize {
+		return
+	}
+	bufferPool.Put(buffer)
+}
+
+// defaultSeverityMap returns a copy of the default severity map.
+func defaultSeverityMap() map[logrus.Level]logging.Severity {
+	severityMap := map[logrus.Level]logging.Severity{}
+	for level, severity := range logrusToGoogleSeverityMap {
+		severityMap[level] = severity
+	}
+	return severityMap
+}
+
 // Formatter is the logrus formatter.
 type Formatter struct {
-	Labels map[string]string // This is an optional map of additional "labels".
+	Labels          map[string]string                 // These are the entry labels (see `LabelsKey`), not the monitored resource's labels (see `ResourceLabels`); once the formatter is in use, use `SetLabels`, `AddLabel`, and `DeleteLabel` to change it.
+	ReportCaller    bool                              // If true, then include the source location of the caller.
+	ProjectID       string                            // If set, then the trace will be fully qualified as "projects/PROJECT_ID/traces/TRACE_ID".
+	SeverityMap     map[logrus.Level]logging.Severity // This maps a logrus level to a Google severity; if nil, then the default mapping is used.
+	DefaultSeverity logging.Severity                  // This is the severity for any level that is not in the severity map (such as a custom level).
+	TraceKey        string                            // This is the key for the trace; if empty, then `TraceKey` ("logging.googleapis.com/trace") is used.
+	SpanIDKey       string                            // This is the key for the span ID; if empty, then `SpanKey` ("logging.googleapis.com/spanId") is used.
+
+	DisableTimestamp bool           // If true, then do not include the timestamp; Google will use the time that the entry was received.
+	DisableNewline   bool           // If true, then do not end the entry with a newline (for outputs that add their own delimiter).
+	InsertIDFunc     InsertIDFunc   // If set, then this generates the insert ID for each entry.
+	LabelKeys        []string       // These logrus fields are moved into the labels; a static label with the same key takes precedence.
+	LabelExtractor   LabelExtractor // If set, then this adds labels from the entry's context; a static label with the same key takes precedence.
+
+	ReservedKeyPolicy     ReservedKeyPolicy        // This determines what happens when a logrus field collides with a key written by the formatter.
+	ErrorReporting        *ErrorReporting          // If set, then entries with a severity of Error or worse are reported to Error Reporting.
+	StackTraces           bool                     // If true, then include the stack trace of the logrus error field, if it has one.
+	OrderedKeys           bool                     // If true, then write the formatter's keys first in a fixed order, followed by the logrus fields in sorted order.
+	MessageKey            string                   // This is the key for the message; if empty, then `MessageKey` ("message") is used (unlike `WithMessageKey("")`, which sets `OmitMessage`).
+	OmitEmptyMessage      bool                     // If true, then the message is omitted when it is empty.
+	OmitMessage           bool                     // If true, then the message is always omitted (for purely structured entries).
+	DataKey               string                   // If set, then the logrus fields are nested under this key instead of being written at the top level.
+	RedactKeys            []string                 // The values of logrus fields with these keys (case-insensitive) are replaced with `RedactedValue`.
+	RedactSubstrings      bool                     // If true, then a key is redacted if it contains any of the `RedactKeys`.
+	RedactPatterns        []*regexp.Regexp         // Matches of these patterns in the message and string field values are replaced with `RedactedValue`.
+	MaxFieldBytes         int                      // If positive, then the message and string field values are truncated to this many bytes.
+	MaxEntryBytes         int                      // If positive, then the logrus fields are dropped and the message is truncated when an entry is larger than this many bytes.
+	FlatTraceFields       bool                     // If true, then also write the trace and span IDs as plain "trace_id" and "span_id" fields.
+	ValueMarshaler        ValueMarshaler           // If set, then this converts each logrus field value before it is written.
+	EscapeHTML            bool                     // If true, then "<", ">", and "&" are escaped (as `json.Marshal` does); by default, they are written as-is.
+	MaxSeverity           logging.Severity         // If set, then the severity is capped at this value after it has been mapped.
+	LabelsMode            LabelsMode               // This determines which key (or keys) the labels are written to.
+	Resource              *Resource                // If set (and not empty), then this is written as the monitored resource.
+	DurationFormat        DurationFormat           // This determines how `time.Duration` field values are written.
+	MinLevel              logrus.Level             // If set, then entries less severe than this level are dropped; the zero value (`logrus.PanicLevel`) drops nothing unless `MinLevelSet` is set.
+	MinLevelSet           bool                     // If true, then `MinLevel` is used even if it is `logrus.PanicLevel` (so that only Panic entries are written); `WithMinLevel` sets this.
+	SampleRates           map[logrus.Level]float64 // This is the fraction of entries to keep for each level (for example, 0.1 keeps 10%); Error and more severe levels are always kept.
+	ComponentField        string                   // If set, then this logrus field is written as the component (see `ComponentKey`) instead of as a regular field.
+	SanitizeLabels        bool                     // If true, then invalid characters in label keys are replaced with "_", long keys are truncated to `MaxLabelKeyBytes`, and empty keys are dropped.
+	ParseJSONStringFields []string                 // The string values of these logrus fields are written as JSON objects (or arrays) if they hold valid JSON.
+	MultilineMode         MultilineMode            // This determines how line breaks in the message are written.
+	Pretty                bool                     // If true, then the JSON is indented with two spaces for reading in a terminal; each entry spans several lines, so this is only for local development.
+	TraceFlagsAndState    bool                     // If true, then also write the trace flags and trace state of a valid OpenTelemetry span context.
+	TimeLayout            string                   // If set, then `time.Time` field values are converted to UTC and written with this layout (such as `time.RFC3339Nano`, which matches the timestamp).
+	SeverityStringer      SeverityStringer         // If set, then this converts the severity into the string that is written (for example, `UppercaseSeverity`); by default, `logging.Severity.String` is used.
+	DropFunc              DropFunc                 // If set, then entries for which this returns true are dropped (after `MinLevel`, but before `SampleRates`).
+	BaggageLabelKeys      []string                 // These OpenTelemetry baggage members are copied into the labels; a static or extracted label with the same key takes precedence.
+	MaxNestingDepth       int                      // If positive, then maps and slices (including structs) nested deeper than this in a logrus field are replaced with `TooDeepValue`.
+	FieldMap              map[string]string        // This renames the severity, message, and timestamp keys (for example, {"message": "msg", "severity": "level"}), like logrus's `JSONFormatter.FieldMap`.
+	GroupHash             bool                     // If true, then a hash of the message template and the `GroupHashFields` is written as the `GroupHashLabel` label, so that repeated entries can be grouped.
+	GroupHashFields       []string                 // These logrus fields are included in the group hash (see `GroupHash`).
+	GroupHashFunc         GroupHashFunc            // If set, then this computes the group hash; by default, `DefaultGroupHash` is used.
+	OnFormat              OnFormatFunc             // If set, then this is called at the end of each successful `Format` with the entry's severity and size.
+	TracePrecedence       TracePrecedence          // This determines whether the OpenTelemetry span (the default) or the context keys (such as `ContextKeyTrace`) win when both have a trace.
+	ResourceLabels        map[string]string        // These labels are added to the monitored resource's labels (taking precedence over `Resource.Labels`); use these for instance metadata rather than the entry labels.
+	DedupWindow           time.Duration            // If positive, then an entry with the same key (see `DedupKeyFunc`) as one written less than this long ago is dropped; the next one written after the window (or a summary, if there is none) has the number dropped as `RepeatedKey`.
+	DedupKeyFunc          DedupKeyFunc             // If set, then this returns the key by which duplicate entries are recognized; by default, `DefaultDedupKey` (the level and message) is used.
+	RetentionKey          string                   // This is the key for the retention hint (a passthrough for routing entries to a log bucket); if empty, then `RetentionKey` ("retention_days") is used.
+	DefaultRetentionDays  int                      // If positive, then this is the retention hint for entries without a `RetentionField`.
+	ProtoJSON             bool                     // If true, then logrus field values that are protobuf messages are written with `protojson` (so enums are written by name and oneofs are written as their set field).
+	OversizedBytes        int                      // If positive, then this is the size above which `OnOversized` is called; otherwise, `MaxEntryBytes` (if set) or `CloudLoggingMaxEntryBytes` is used.
+	OnOversized           OversizedFunc            // If set, then this is called by `Format` for each entry that is larger than `OversizedBytes` (before it is shrunk to fit `MaxEntryBytes`).
+	SpanIDMode            SpanIDMode               // This determines what happens to a span ID that is not 16 hexadecimal characters (such as one from a custom propagator).
+	MarshalFallback       bool                     // If true, then an entry that cannot be built or marshaled (such as when `ValueMarshaler` fails) is written as a minimal line with its message and the error (see `FormatErrorKey`) instead of `Format` returning an error (which makes logrus drop it).
+
+	labelsMutex sync.RWMutex // This guards `Labels`.
+	sampleMutex sync.Mutex   // This guards `sampleRand`.
+	sampleRand  *rand.Rand   // If set, then this is used for sampling instead of the global source.
+
+	deduplicator deduplicator     // This keeps track of the entries that have been written recently (see `DedupWindow`).
+	nowFunc      func() time.Time // If set, then this returns the current time instead of `time.Now` (for tests).
 }
 
 // New creates a new formatter.
-func New() *Formatter {
+func New(options ...Option) *Formatter {
 	f := &Formatter{
-		Labels: map[string]string{},
+		Labels:      map[string]string{},
+		SeverityMap: defaultSeverityMap(),
+		MessageKey:  MessageKey,
+	}
+	for _, option := range options {
+		option(f)
 	}
 	return f
 }
 
-// AddLabel adds a label to the formatter.
-func (f *Formatter) AddLabel(key, value string) {
-	f.Labels[key] = value
+// SetSeverity sets the Google severity for a logrus level.
+func (f *Formatter) SetSeverity(level logrus.Level, severity logging.Severity) {
+	if f.SeverityMap == nil {
+		f.SeverityMap = defaultSeverityMap()
+	}
+	f.SeverityMap[level] = severity
+}
+
+// Clone returns a deep copy of the formatter.
+//
+// The maps, slices, and pointers are copied, so the clone may be customized (for example, with request-scoped labels)
+// without affecting the original; the two may be used concurrently.
+// A seeded sampling source (see `WithSampleSeed`) is not shared; the clone uses the global source.
+// The record of recent entries (see `DedupWindow`) is not shared either; the clone starts with none.
+func (f *Formatter) Clone() *Formatter {
+	f.labelsMutex.RLock()
+	labels := copyStringMap(f.Labels)
+	f.labelsMutex.RUnlock()
+
+	clone := &Formatter{
+		Labels:          labels,
+		ReportCaller:    f.ReportCaller,
+		ProjectID:       f.ProjectID,
+		DefaultSeverity: f.DefaultSeverity,
+		TraceKey:        f.TraceKey,
+		SpanIDKey:       f.SpanIDKey,
+
+		DisableTimestamp: f.DisableTimestamp,
+		DisableNewline:   f.DisableNewline,
+		InsertIDFunc:     f.InsertIDFunc,
+		LabelExtractor:   f.LabelExtractor,
+
+		ReservedKeyPolicy:    f.ReservedKeyPolicy,
+		StackTraces:          f.StackTraces,
+		OrderedKeys:          f.OrderedKeys,
+		MessageKey:           f.MessageKey,
+		OmitEmptyMessage:     f.OmitEmptyMessage,
+		OmitMessage:          f.OmitMessage,
+		DataKey:              f.DataKey,
+		RedactSubstrings:     f.RedactSubstrings,
+		MaxFieldBytes:        f.MaxFieldBytes,
+		MaxEntryBytes:        f.MaxEntryBytes,
+		FlatTraceFields:      f.FlatTraceFields,
+		ValueMarshaler:       f.ValueMarshaler,
+		EscapeHTML:           f.EscapeHTML,
+		MaxSeverity:          f.MaxSeverity,
+		LabelsMode:           f.LabelsMode,
+		DurationFormat:       f.DurationFormat,
+		MinLevel:             f.MinLevel,
+		MinLevelSet:          f.MinLevelSet,
+		ComponentField:       f.ComponentField,
+		SanitizeLabels:       f.SanitizeLabels,
+		MultilineMode:        f.MultilineMode,
+		Pretty:               f.Pretty,
+		TraceFlagsAndState:   f.TraceFlagsAndState,
+		TimeLayout:           f.TimeLayout,
+		SeverityStringer:     f.SeverityStringer,
+		DropFunc:             f.DropFunc,
+		MaxNestingDepth:      f.MaxNestingDepth,
+		GroupHash:            f.GroupHash,
+		GroupHashFunc:        f.GroupHashFunc,
+		OnFormat:             f.OnFormat,
+		TracePrecedence:      f.TracePrecedence,
+		DedupWindow:          f.DedupWindow,
+		DedupKeyFunc:         f.DedupKeyFunc,
+		RetentionKey:         f.RetentionKey,
+		DefaultRetentionDays: f.DefaultRetentionDays,
+		ProtoJSON:            f.ProtoJSON,
+		OversizedBytes:       f.OversizedBytes,
+		OnOversized:          f.OnOversized,
+		SpanIDMode:           f.SpanIDMode,
+		MarshalFallback:      f.MarshalFallback,
+		nowFunc:              f.nowFunc,
+	}
+	if f.SeverityMap != nil {
+		clone.SeverityMap = make(map[logrus.Level]logging.Severity, len(f.SeverityMap))
+		for level, severity := range f.SeverityMap {
+			clone.SeverityMap[level] = severity
+		}
+	}
+	if f.LabelKeys != nil {
+		clone.LabelKeys = append([]string{}, f.LabelKeys...)
+	}
+	if f.ErrorReporting != nil {
+		errorReporting := *f.ErrorReporting
+		clone.ErrorReporting = &errorReporting
+	}
+	if f.BaggageLabelKeys != nil {
+		clone.BaggageLabelKeys = append([]string{}, f.BaggageLabelKeys...)
+	}
+	if f.ParseJSONStringFields != nil {
+		clone.ParseJSONStringFields = append([]string{}, f.ParseJSONStringFields...)
+	}
+	if f.RedactKeys != nil {
+		clone.RedactKeys = append([]string{}, f.RedactKeys...)
+	}
+	if f.RedactPatterns != nil {
+		clone.RedactPatterns = append([]*regexp.Regexp{}, f.RedactPatterns...)
+	}
+	if f.Resource != nil {
+		clone.Resource = &Resource{
+			Type:   f.Resource.Type,
+			Labels: copyStringMap(f.Resource.Labels),
+		}
+	}
+	if f.GroupHashFields != nil {
+		clone.GroupHashFields = append([]string{}, f.GroupHashFields...)
+	}
+	if f.ResourceLabels != nil {
+		clone.ResourceLabels = copyStringMap(f.ResourceLabels)
+	}
+	if f.FieldMap != nil {
+		clone.FieldMap = copyStringMap(f.FieldMap)
+	}
+	if f.SampleRates != nil {
+		clone.SampleRates = make(map[logrus.Level]float64, len(f.SampleRates))
+		for level, rate := range f.SampleRates {
+			clone.SampleRates[level] = rate
+		}
+	}
+	return clone
+}
+
+// now returns the current time.
+func (f *Formatter) now() time.Time {
+	if f.nowFunc != nil {
+		return f.nowFunc()
+	}
+	return time.Now()
+}
+
+// copyStringMap returns a copy of the map; a nil map stays nil.
+func copyStringMap(input map[string]string) map[string]string {
+	if input == nil {
+		return nil
+	}
+	output := make(map[string]string, len(input))
+	for key, value := range input {
+		output[key] = value
+	}
+	return output
+}
+
+// severity returns the Google severity for a logrus level.
+//
+// A level that is not in the severity map uses `DefaultSeverity`.
+// The severity is capped at `MaxSeverity`, if set.
+func (f *Formatter) severity(level logrus.Level) logging.Severity {
+	severityMap := f.SeverityMap
+	if severityMap == nil {
+		severityMap = logrusToGoogleSeverityMap
+	}
+	severity, okay := severityMap[level]
+	if !okay {
+		severity = f.DefaultSeverity
+	}
+	if f.MaxSeverity != logging.Default && severity > f.MaxSeverity {
+		severity = f.MaxSeverity
+	}
+	return severity
+}
+
+// entrySeverity returns the Google severity for the entry.
+//
+// A `SeverityField` field or (failing that) a `ContextKeySeverity` value in the entry's context takes the place of
+// the severity for the entry's level.
+// The severity is capped at `MaxSeverity`, if set.
+func (f *Formatter) entrySeverity(entry *logrus.Entry) logging.Severity {
+	severity, okay := parseSeverity(entryFields(entry)[SeverityField])
+	if !okay && entry.Context != nil {
+		severity, okay = severityFromContext(entry.Context)
+	}
+	if !okay {
+		return f.severity(entry.Level)
+	}
+	if f.MaxSeverity != logging.Default && severity > f.MaxSeverity {
+		severity = f.MaxSeverity
+	}
+	return severity
+}
+
+// isDropped returns true if the entry should not be written at all.
+func (f *Formatter) isDropped(entry *logrus.Entry) bool {
+	if (f.MinLevelSet || f.MinLevel != logrus.PanicLevel) && entry.Level > f.MinLevel {
+		return true
+	}
+	if f.DropFunc != nil && f.DropFunc(entry) {
+		return true
+	}
+	if f.isSampledOut(entry.Level) {
+		return true
+	}
+	return false
 }
 
 // Levels are the available logging levels.
@@ -65,39 +441,364 @@ func (f *Formatter) Levels() []logrus.Level {
 }
 
 // Format an entry.
+//
+// A value that cannot be encoded is normally replaced (see `replaceUnserializable`); if the entry still cannot be
+// encoded, then the error names the entry's level and (if it can be found) the key of the offending field.
+//
+// If the entry is dropped (see `MinLevel`, `DropFunc`, `SampleRates`, and `DedupWindow`), then this returns an empty slice and no error.
+// logrus still passes the empty slice to the logger's output, which writes nothing; hooks are still fired.
+//
+// With `DedupWindow`, the result may start with summary lines for other entries whose window has closed (see `WithDedup`);
+// a summary may be all that is returned for a suppressed entry.
+//
+// If the entry is nil, then this returns `ErrNilEntry`.
 func (f *Formatter) Format(entry *logrus.Entry) ([]byte, error) {
-	severity := logging.Default
-	if value, okay := logrusToGoogleSeverityMap[entry.Level]; okay {
-		severity = value
+	if entry == nil {
+		return nil, ErrNilEntry
+	}
+	if f.isDropped(entry) {
+		return []byte{}, nil
+	}
+	repeated, suppressed, summaries := f.dedup(&f.deduplicator, entry)
+	var summaryLines []byte
+	for _, mapEntry := range f.summaryEntries(entry, summaries) {
+		if line, err := f.Marshal(mapEntry); err == nil {
+			summaryLines = append(summaryLines, line...)
+		}
+	}
+	if suppressed {
+		if summaryLines != nil {
+			return summaryLines, nil
+		}
+		return []byte{}, nil
 	}
 
-	mapEntry := map[string]interface{}{}
-	mapEntry[SeverityKey] = severity.String()
-	mapEntry[MessageKey] = entry.Message
+	contents, err := f.formatEntry(entry, repeated)
+	if err != nil || summaryLines == nil {
+		return contents, err
+	}
+	return append(summaryLines, contents...), nil
+}
+
+// formatEntry formats an entry that has not been dropped, with the given number of repeated entries (see `DedupWindow`).
+func (f *Formatter) formatEntry(entry *logrus.Entry, repeated int) ([]byte, error) {
+	mapEntry, err := f.buildEntry(entry, repeated)
+	if err != nil {
+		if f.MarshalFallback {
+			return f.fallbackLine(entry, err), nil
+		}
+		return nil, err
+	}
+
+	// Write directly into the buffer that logrus provides, if any.
+	return f.encodeEntry(entry, mapEntry, entry.Buffer)
+}
+
+// encodeEntry writes the map entry built for the entry to the target buffer (or to a new one, if the target is nil),
+// with the same fallback and callbacks as `Format`.
+func (f *Formatter) encodeEntry(entry *logrus.Entry, mapEntry map[string]interface{}, target *bytes.Buffer) ([]byte, error) {
+	buffer := getBuffer(target)
+	defer putBuffer(buffer)
+
+	size, err := f.marshal(buffer, mapEntry)
+	if err != nil {
+		err = marshalError(buffer, mapEntry, []string{"level=" + entry.Level.String()}, err)
+		if f.MarshalFallback {
+			return f.fallbackLine(entry, err), nil
+		}
+		return nil, err
+	}
+	if f.OnOversized != nil && size > f.oversizedBytes() {
+		f.OnOversized(size, entry)
+	}
+	if f.OnFormat != nil {
+		f.OnFormat(f.entrySeverity(entry), buffer.Len())
+	}
+	if target != nil {
+		return target.Bytes(), nil
+	}
+	contents := make([]byte, buffer.Len())
+	copy(contents, buffer.Bytes())
+	return contents, nil
+}
+
+// Marshal writes a map entry from `BuildEntry` in the same way that `Format` encodes it, so a caller may inspect
+// or change the map entry in between.
+// The map entry may be changed (for example, if it has to be shrunk to fit `MaxEntryBytes`).
+//
+// Only the encoding is the same: `Marshal` has no logrus entry, so unlike `Format`, it does not drop entries
+// (`MinLevel`, `DropFunc`, `SampleRates`, and `DedupWindow`, including its summaries), does not write the `MarshalFallback`
+// line (it returns the error instead), and does not call `OnOversized` or `OnFormat`.
+func (f *Formatter) Marshal(mapEntry map[string]interface{}) ([]byte, error) {
+	buffer := getBuffer(nil)
+	defer putBuffer(buffer)
+
+	if _, err := f.marshal(buffer, mapEntry); err != nil {
+		return nil, marshalError(buffer, mapEntry, nil, err)
+	}
+	contents := make([]byte, buffer.Len())
+	copy(contents, buffer.Bytes())
+	return contents, nil
+}
+
+// marshal writes the map entry to the buffer, followed by a newline (unless `DisableNewline` is set).
+//
+// This returns the size of the entry (including the newline) before it was shrunk to fit `MaxEntryBytes`, if it was.
+func (f *Formatter) marshal(buffer *encodeBuffer, mapEntry map[string]interface{}) (int, error) {
+	err := f.encode(buffer, mapEntry)
+	if err != nil && f.replaceUnserializable(buffer, mapEntry) {
+		err = f.encode(buffer, mapEntry)
+	}
+	size := buffer.Len()
+	if err == nil && f.MaxEntryBytes > 0 && buffer.Len() > f.MaxEntryBytes {
+		err = f.shrink(buffer, mapEntry)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if f.DisableNewline {
+		buffer.Truncate(buffer.Len() - 1)
+	}
+	return size, nil
+}
+
+// marshalError wraps an error from `marshal` with the given details (such as "level=info") and, if it can be found,
+// the key of the offending field.
+func marshalError(buffer *encodeBuffer, mapEntry map[string]interface{}, details []string, err error) error {
+	if key := findUnserializableKey(buffer, mapEntry); key != "" {
+		details = append(details, fmt.Sprintf("field=%q", key))
+	}
+	if len(details) == 0 {
+		return fmt.Errorf("gcfstructuredlogformatter: failed to marshal entry: %w", err)
+	}
+	return fmt.Errorf("gcfstructuredlogformatter: failed to marshal entry (%s): %w", strings.Join(details, ", "), err)
+}
+
+// BuildEntry builds the map of everything that is written for the entry; see `Marshal`.
+//
+// The map is new for each entry, but its values may be shared with the formatter and the entry,
+// so replace a value rather than changing it in place.
+//
+// If the entry is nil, then this returns `ErrNilEntry`.
+func (f *Formatter) BuildEntry(entry *logrus.Entry) (map[string]interface{}, error) {
+	return f.buildEntry(entry, 0)
+}
+
+// buildEntry builds the map entry; if the number of repeated entries (see `DedupWindow`) is positive, then it is
+// written as `RepeatedKey` before the logrus fields, so that a field with the same key follows the `ReservedKeyPolicy`.
+func (f *Formatter) buildEntry(entry *logrus.Entry, repeated int) (map[string]interface{}, error) {
+	if entry == nil {
+		return nil, ErrNilEntry
+	}
+	severity := f.entrySeverity(entry)
+	fields := entryFields(entry)
+
+	mapEntry := make(map[string]interface{}, len(entry.Data)+len(reservedKeyOrder))
+	mapEntry[f.fieldKey(SeverityKey)] = f.severityString(severity)
+	if messageKey := f.messageKey(); messageKey != "" && (entry.Message != "" || !f.OmitEmptyMessage) {
+		mapEntry[messageKey] = f.sanitizeString(f.formatMultiline(entry.Message))
+	}
+	if !f.DisableTimestamp && !entry.Time.IsZero() {
+		mapEntry[f.fieldKey(TimestampKey)] = entry.Time.UTC().Format(time.RFC3339Nano)
+	}
+	if template, okay := fields[MessageTemplateField]; okay {
+		mapEntry[MessageTemplateKey] = f.sanitizeString(fmt.Sprint(template))
+	}
+	if f.InsertIDFunc != nil {
+		if insertID := f.InsertIDFunc(entry); insertID != "" {
+			mapEntry[InsertIDKey] = insertID
+		}
+	}
+	if f.ComponentField != "" {
+		if component, okay := fields[f.ComponentField]; okay {
+			mapEntry[ComponentKey] = truncateString(f.redactField(f.ComponentField, fmt.Sprint(component)), f.MaxFieldBytes)
+		}
+	}
+	if retention, okay := f.retention(fields); okay {
+		mapEntry[f.retentionKey()] = retention
+	}
 
 	if entry.Context != nil {
 		// try to get the trace id from the context
-		span := trace.SpanFromContext(entry.Context)
-		spanContext := span.SpanContext()
-		if spanContext.IsValid() {
-			mapEntry[TraceKey] = spanContext.TraceID().String()
-			mapEntry[SpanKey] = spanContext.SpanID().String()
+		if traceContext, okay := traceFromContext(entry.Context, f.TracePrecedence); okay {
+			spanID := f.spanID(traceContext.SpanID)
+			mapEntry[f.traceKey()] = f.qualifyTrace(traceContext.TraceID)
+			mapEntry[TraceSampledKey] = traceContext.Sampled
+			if spanID != "" {
+				mapEntry[f.spanKey()] = spanID
+			}
+			if f.FlatTraceFields {
+				mapEntry[FlatTraceIDKey] = bareTraceID(traceContext.TraceID)
+				if spanID != "" {
+					mapEntry[FlatSpanIDKey] = spanID
+				}
+			}
+			if f.TraceFlagsAndState {
+				// The flags and state are only written if they belong to the trace that was written.
+				if spanContext := trace.SpanContextFromContext(entry.Context); spanContext.IsValid() && spanContext.TraceID().String() == bareTraceID(traceContext.TraceID) {
+					mapEntry[TraceFlagsKey] = spanContext.TraceFlags().String()
+					if traceState := spanContext.TraceState(); traceState.Len() > 0 {
+						mapEntry[TraceStateKey] = traceState.String()
+					}
+				}
+			}
+		}
+
+		switch operation := entry.Context.Value(ContextKeyOperation).(type) {
+		case Operation:
+			mapEntry[OperationKey] = operation
+		case *Operation:
+			if operation != nil {
+				mapEntry[OperationKey] = operation
+			}
+		}
+
+		switch httpRequest := entry.Context.Value(ContextKeyHTTPRequest).(type) {
+		case HTTPRequest:
+			mapEntry[HTTPRequestKey] = httpRequest
+		case *HTTPRequest:
+			if httpRequest != nil {
+				mapEntry[HTTPRequestKey] = httpRequest
+			}
 		}
 	}
-	if len(f.Labels) > 0 {
-		labels := map[string]string{}
-		for key, value := range f.Labels {
-			labels[key] = value
+	if f.ReportCaller && !hasSourceLocation(fields) {
+		caller := entry.Caller
+		if caller == nil {
+			caller = getCaller()
+		}
+		if caller != nil {
+			mapEntry[SourceLocationKey] = SourceLocation{
+				File:     caller.File,
+				Line:     caller.Line,
+				Function: caller.Function,
+			}
 		}
-		mapEntry[LabelsKey] = labels
+	}
+	if f.ErrorReporting != nil && severity >= logging.Error {
+		mapEntry[ErrorReportingTypeKey] = ErrorReportingType
+		mapEntry[ServiceContextKey] = serviceContext{
+			Service: f.ErrorReporting.Service,
+			Version: f.ErrorReporting.Version,
+		}
+	}
+	if f.StackTraces {
+		if stack := stackTrace(entry); stack != "" {
+			mapEntry[StackTraceKey] = stack
+		}
+	}
+	if resource := f.resource(); !resource.isEmpty() {
+		mapEntry[ResourceKey] = resource
+	}
+	// The labels are only written if the merged labels (static, extracted, and promoted) are not empty.
+	if labels := f.labels(entry, fields); labels != nil {
+		f.writeLabels(mapEntry, labels)
+	}
+	if repeated > 0 {
+		mapEntry[RepeatedKey] = repeated
 	}
 
-	for key, value := range entry.Data {
-		mapEntry[key] = value
+	var data map[string]interface{}
+	if f.DataKey != "" {
+		data = make(map[string]interface{}, len(fields))
 	}
-	contents, err := json.Marshal(mapEntry)
-	if err != nil {
-		return nil, err
+	for key, value := range fields {
+		if f.isLabelKey(key) {
+			continue
+		}
+		if f.ComponentField != "" && key == f.ComponentField {
+			continue
+		}
+		if key == RetentionField {
+			continue
+		}
+		if key == SeverityField {
+			if _, okay := parseSeverity(value); okay {
+				continue
+			}
+		}
+		if key == MessageTemplateField {
+			continue
+		}
+		switch value.(type) {
+		case HTTPRequest, *HTTPRequest:
+			if key == HTTPRequestKey {
+				// The HTTP request is always written at the top level.
+				mapEntry[HTTPRequestKey] = value
+				continue
+			}
+		case Operation, *Operation:
+			if key == OperationKey {
+				// The operation is always written at the top level.
+				mapEntry[OperationKey] = value
+				continue
+			}
+		case SourceLocation, *SourceLocation:
+			if key == SourceLocationKey {
+				// The source location is always written at the top level, in place of the caller.
+				mapEntry[SourceLocationKey] = value
+				continue
+			}
+		}
+		if f.ValueMarshaler != nil {
+			var err error
+			value, err = f.ValueMarshaler(key, value)
+			if err != nil {
+				return nil, fmt.Errorf("could not marshal field %q: %w", key, err)
+			}
+		}
+		value = errorString(value)
+		if f.ProtoJSON {
+			var err error
+			value, err = marshalProto(value)
+			if err != nil {
+				return nil, fmt.Errorf("could not marshal field %q: %w", key, err)
+			}
+		}
+		if f.isJSONStringField(key) {
+			value = parseJSONString(value)
+		}
+		if f.MaxNestingDepth > 0 {
+			value = f.limitDepth(value, 1)
+		}
+		if f.isSanitizing() {
+			value = f.sanitize(key, value)
+		}
+		if data != nil {
+			data[key] = value
+		} else if err := f.addField(mapEntry, key, value); err != nil {
+			return nil, err
+		}
+	}
+	if len(data) > 0 {
+		if err := f.addField(mapEntry, f.DataKey, data); err != nil {
+			return nil, err
+		}
+	}
+	return mapEntry, nil
+}
+
+// encode resets the buffer and writes the map entry to it, followed by a newline.
+func (f *Formatter) encode(buffer *encodeBuffer, mapEntry map[string]interface{}) error {
+	buffer.Reset()
+	buffer.encoder.SetEscapeHTML(f.EscapeHTML)
+	var err error
+	if f.OrderedKeys {
+		err = encodeOrdered(buffer, mapEntry, f.reservedKeys(mapEntry))
+	} else {
+		// The encoder writes the same bytes as `json.Marshal` (aside from the HTML escaping), followed by a newline.
+		err = buffer.encoder.Encode(mapEntry)
+	}
+	if err != nil || !f.Pretty {
+		return err
+	}
+
+	// Indenting keeps the key order, and the trailing newline is kept as-is.
+	var indented bytes.Buffer
+	if err = json.Indent(&indented, buffer.Bytes(), "", "  "); err != nil {
+		return err
 	}
-	return append(contents, []byte("\n")...), nil
+	buffer.Reset()
+	_, err = buffer.Write(indented.Bytes())
+	return err
 }
@@ -1,8 +1,15 @@
 package gcfstructuredlogformatter
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
 	"testing"
+	"time"
 
+	"cloud.google.com/go/logging"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,6 +41,66 @@ func TestFormat(t *testing.T) {
 			}(),
 			output: []byte(`{"message":"test","prop":"value","severity":"Info"}` + "\n"),
 		},
+		{
+			description: "Trace Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.TraceLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Default"}` + "\n"),
+		},
+		{
+			description: "Debug Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.DebugLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Debug"}` + "\n"),
+		},
+		{
+			description: "Warning Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.WarnLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Error Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.ErrorLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Fatal Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.FatalLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Alert"}` + "\n"),
+		},
+		{
+			description: "Panic Entry",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.PanicLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Emergency"}` + "\n"),
+		},
 	}
 
 	for _, row := range rows {
@@ -46,6 +113,23 @@ func TestFormat(t *testing.T) {
 	}
 }
 
+func TestFormatSeverityRoundTrip(t *testing.T) {
+	formatter := New()
+	for _, level := range formatter.Levels() {
+		t.Run(level.String(), func(t *testing.T) {
+			e := logrus.NewEntry(logrus.New())
+			e.Level = level
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+
+			var output map[string]interface{}
+			err = json.Unmarshal(result, &output)
+			require.Nil(t, err)
+			assert.Equal(t, logrusToGoogleSeverityMap[level], logging.ParseSeverity(output[SeverityKey].(string)))
+		})
+	}
+}
+
 func TestFormatWithLabels(t *testing.T) {
 	logger := logrus.New()
 	rows := []struct {
@@ -76,3 +160,1295 @@ func TestFormatWithLabels(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatWithOperation(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Operation ID",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyOperation, Operation{ID: "my-operation"})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/operation":{"id":"my-operation"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Operation Full",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyOperation, &Operation{ID: "my-operation", Producer: "my-producer", First: true, Last: true})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/operation":{"id":"my-operation","producer":"my-producer","first":true,"last":true},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Operation Nil",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyOperation, (*Operation)(nil))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Operation Field",
+			input: func() *logrus.Entry {
+				e := WithOperation(logger.WithField("key", "value"), Operation{ID: "my-operation", Last: true})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","logging.googleapis.com/operation":{"id":"my-operation","last":true},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Operation Field with Data Key",
+			options:     []Option{WithDataKey("data")},
+			input: func() *logrus.Entry {
+				e := WithOperation(logger.WithField("key", "value"), Operation{ID: "my-operation"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"data":{"key":"value"},"logging.googleapis.com/operation":{"id":"my-operation"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithSeverity(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Overridden Level",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.WarnLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Notice"}` + "\n"),
+		},
+		{
+			description: "Default Level",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.ErrorLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New()
+			formatter.SetSeverity(logrus.WarnLevel, logging.Notice)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithTimestamp(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Timestamp",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 123456789, time.FixedZone("EST", -5*60*60))
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info","time":"2024-06-15T13:30:15.123456789Z"}` + "\n"),
+		},
+		{
+			description: "Disabled Timestamp",
+			options:     []Option{WithDisableTimestamp(true)},
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 123456789, time.UTC)
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	logger := logrus.New()
+	formatter := New(WithLabels(map[string]string{"service": "my-service", "version": "1.0.0"}))
+	e := logger.WithFields(logrus.Fields{"prop": "value", "count": 42, "enabled": true})
+	e.Message = "test"
+	e.Level = logrus.InfoLevel
+	e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := formatter.Format(e)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestFormatWithMessageKey(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"message":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Custom",
+			options:     []Option{WithMessageKey("msg")},
+			output:      []byte(`{"msg":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty",
+			options:     []Option{WithMessageKey("")},
+			output:      []byte(`{"prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty then Custom",
+			options:     []Option{WithMessageKey(""), WithMessageKey("msg")},
+			output:      []byte(`{"msg":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Omitted",
+			options:     []Option{WithOmitMessage(true)},
+			output:      []byte(`{"prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Omitted with Custom Key",
+			options:     []Option{WithMessageKey("msg"), WithOmitMessage(true)},
+			output:      []byte(`{"prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Custom Ordered",
+			options:     []Option{WithMessageKey("msg"), WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","msg":"test","prop":"value"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(logrus.Fields{"prop": "value"})
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithStructLiteral(t *testing.T) {
+	e := logrus.NewEntry(logrus.New()).WithField("k", "v")
+	e.Message = "test"
+	e.Level = logrus.InfoLevel
+
+	formatter := &Formatter{}
+	result, err := formatter.Format(e)
+	require.Nil(t, err)
+	assert.Equal(t, `{"k":"v","message":"test","severity":"Info"}`+"\n", string(result))
+
+	formatter = &Formatter{Labels: map[string]string{"service": "api"}}
+	result, err = formatter.Format(e)
+	require.Nil(t, err)
+	assert.Equal(t, `{"k":"v","logging.googleapis.com/labels":{"service":"api"},"message":"test","severity":"Info"}`+"\n", string(result))
+}
+
+func TestFormatWithOmitEmptyMessage(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		message     string
+		output      []byte
+	}{
+		{
+			description: "Empty Message",
+			message:     "",
+			output:      []byte(`{"logging.googleapis.com/labels":{"key":"value"},"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty Message Omitted",
+			options:     []Option{WithOmitEmptyMessage(true)},
+			message:     "",
+			output:      []byte(`{"logging.googleapis.com/labels":{"key":"value"},"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Message Not Omitted",
+			options:     []Option{WithOmitEmptyMessage(true)},
+			message:     "test",
+			output:      []byte(`{"logging.googleapis.com/labels":{"key":"value"},"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000"})
+			e := logger.WithContext(ctx)
+			e.Message = row.message
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append(row.options, WithLabels(map[string]string{"key": "value"}))...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithDataKey(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Flattened",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value", "severity": "bogus"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"fields.severity":"bogus","message":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nested",
+			options:     []Option{WithDataKey("data")},
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value", "severity": "bogus"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"data":{"prop":"value","severity":"bogus"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nested without Fields",
+			options:     []Option{WithDataKey("data")},
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nested with HTTP Request and Labels",
+			options:     []Option{WithDataKey("data"), WithLabelKeys("tenant")},
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value", "tenant": "acme", HTTPRequestKey: &HTTPRequest{Status: 200}})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"data":{"prop":"value"},"httpRequest":{"status":200},"logging.googleapis.com/labels":{"tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithEntryBuffer(t *testing.T) {
+	logger := logrus.New()
+	output := []byte(`{"message":"test","prop":"value","severity":"Info"}` + "\n")
+
+	t.Run("Nil Buffer", func(t *testing.T) {
+		e := logger.WithFields(logrus.Fields{"prop": "value"})
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		require.Nil(t, e.Buffer)
+
+		formatter := New()
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, output, result)
+	})
+
+	t.Run("Supplied Buffer", func(t *testing.T) {
+		e := logger.WithFields(logrus.Fields{"prop": "value"})
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		e.Buffer = &bytes.Buffer{}
+		e.Buffer.WriteString("stale contents")
+
+		formatter := New()
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, output, result)
+		assert.Equal(t, output, e.Buffer.Bytes())
+		// The result must be the entry's buffer, not a copy.
+		assert.Equal(t, &e.Buffer.Bytes()[0], &result[0])
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&buffer)
+		logger.SetFormatter(New(WithDisableTimestamp(true)))
+		for i := 0; i < 3; i++ {
+			logger.WithField("prop", "value").Info("test")
+		}
+		assert.Equal(t, string(output)+string(output)+string(output), buffer.String())
+	})
+}
+
+func TestFormatWithEscapeHTML(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"httpRequest":{"requestUrl":"https://example.com/?a=1&b=2"},"message":"<b>&amp;</b>","prop":"<i>","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"<b>&amp;</b>","httpRequest":{"requestUrl":"https://example.com/?a=1&b=2"},"prop":"<i>"}` + "\n"),
+		},
+		{
+			description: "Escaped",
+			options:     []Option{WithEscapeHTML(true)},
+			output:      []byte(`{"httpRequest":{"requestUrl":"https://example.com/?a=1\u0026b=2"},"message":"\u003cb\u003e\u0026amp;\u003c/b\u003e","prop":"\u003ci\u003e","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(logrus.Fields{"prop": "<i>", HTTPRequestKey: &HTTPRequest{RequestURL: "https://example.com/?a=1&b=2"}})
+			e.Message = "<b>&amp;</b>"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithMaxSeverity(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		level       logrus.Level
+		output      []byte
+	}{
+		{
+			description: "Panic",
+			level:       logrus.PanicLevel,
+			output:      []byte(`{"message":"test","severity":"Critical"}` + "\n"),
+		},
+		{
+			description: "Fatal",
+			level:       logrus.FatalLevel,
+			output:      []byte(`{"message":"test","severity":"Critical"}` + "\n"),
+		},
+		{
+			description: "Error",
+			level:       logrus.ErrorLevel,
+			output:      []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Info",
+			level:       logrus.InfoLevel,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(WithMaxSeverity(logging.Critical))
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithResource(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		resource    *Resource
+		output      []byte
+	}{
+		{
+			description: "No Resource",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty Resource",
+			resource:    &Resource{},
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Global Resource",
+			resource:    &Resource{Type: "global"},
+			output:      []byte(`{"message":"test","resource":{"type":"global"},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Resource with Labels",
+			resource:    &Resource{Type: "gce_instance", Labels: map[string]string{"instance_id": "123", "zone": "us-central1-a"}},
+			output:      []byte(`{"message":"test","resource":{"type":"gce_instance","labels":{"instance_id":"123","zone":"us-central1-a"}},"severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New()
+			formatter.Resource = row.resource
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithEntryAndResourceLabels(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Entry Labels",
+			options:     []Option{WithEntryLabels(map[string]string{"request": "1"})},
+			output:      []byte(`{"logging.googleapis.com/labels":{"request":"1"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Resource Labels",
+			options:     []Option{WithResourceLabels(map[string]string{"instance_id": "123"})},
+			output:      []byte(`{"message":"test","resource":{"labels":{"instance_id":"123"}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Both",
+			options: []Option{
+				WithEntryLabels(map[string]string{"request": "1"}),
+				WithResourceLabels(map[string]string{"instance_id": "123"}),
+			},
+			output: []byte(`{"logging.googleapis.com/labels":{"request":"1"},"message":"test","resource":{"labels":{"instance_id":"123"}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Resource Labels with Resource",
+			options: []Option{
+				WithResource("gce_instance", map[string]string{"instance_id": "123", "zone": "us-central1-a"}),
+				WithResourceLabels(map[string]string{"zone": "us-east1-b", "project_id": "project"}),
+			},
+			output: []byte(`{"message":"test","resource":{"type":"gce_instance","labels":{"instance_id":"123","project_id":"project","zone":"us-east1-b"}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Labels Alias",
+			options: []Option{
+				WithLabels(map[string]string{"first": "1"}),
+				WithEntryLabels(map[string]string{"second": "2"}),
+			},
+			output: []byte(`{"logging.googleapis.com/labels":{"first":"1","second":"2"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+
+	t.Run("Resource Untouched", func(t *testing.T) {
+		formatter := New(
+			WithResource("gce_instance", map[string]string{"zone": "us-central1-a"}),
+			WithResourceLabels(map[string]string{"zone": "us-east1-b"}),
+		)
+		e := logrus.NewEntry(logger)
+		e.Level = logrus.InfoLevel
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, map[string]string{"zone": "us-central1-a"}, formatter.Resource.Labels)
+	})
+}
+
+func TestFormatWithMinLevel(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		level       logrus.Level
+		output      []byte
+	}{
+		{
+			description: "Not Set",
+			level:       logrus.TraceLevel,
+			output:      []byte(`{"message":"test","severity":"Default"}` + "\n"),
+		},
+		{
+			description: "More Severe",
+			options:     []Option{WithMinLevel(logrus.InfoLevel)},
+			level:       logrus.WarnLevel,
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "At Threshold",
+			options:     []Option{WithMinLevel(logrus.InfoLevel)},
+			level:       logrus.InfoLevel,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Below Threshold",
+			options:     []Option{WithMinLevel(logrus.InfoLevel)},
+			level:       logrus.DebugLevel,
+			output:      []byte{},
+		},
+		{
+			description: "Panic Only",
+			options:     []Option{WithMinLevel(logrus.PanicLevel)},
+			level:       logrus.ErrorLevel,
+			output:      []byte{},
+		},
+		{
+			description: "Panic Only at Threshold",
+			options:     []Option{WithMinLevel(logrus.PanicLevel)},
+			level:       logrus.PanicLevel,
+			output:      []byte(`{"message":"test","severity":"Emergency"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+
+	t.Run("Struct Literal", func(t *testing.T) {
+		e := logrus.NewEntry(logger)
+		e.Message = "test"
+		e.Level = logrus.ErrorLevel
+
+		// Without `MinLevelSet`, the zero value drops nothing.
+		result, err := (&Formatter{MinLevel: logrus.PanicLevel}).Format(e)
+		require.Nil(t, err)
+		assert.NotEmpty(t, result)
+
+		result, err = (&Formatter{MinLevel: logrus.PanicLevel, MinLevelSet: true}).Format(e)
+		require.Nil(t, err)
+		assert.Empty(t, result)
+
+		result, err = (&Formatter{MinLevel: logrus.WarnLevel}).Format(e)
+		require.Nil(t, err)
+		assert.NotEmpty(t, result)
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var buffer bytes.Buffer
+		logger := logrus.New()
+		logger.SetLevel(logrus.TraceLevel)
+		logger.SetOutput(&buffer)
+		logger.SetFormatter(New(WithDisableTimestamp(true), WithMinLevel(logrus.InfoLevel)))
+
+		logger.Debug("dropped")
+		logger.Info("kept")
+		assert.Equal(t, `{"message":"kept","severity":"Info"}`+"\n", buffer.String())
+	})
+}
+
+func TestFormatWithDefaultSeverity(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		level       logrus.Level
+		output      []byte
+	}{
+		{
+			description: "Custom Level",
+			level:       logrus.Level(10),
+			output:      []byte(`{"message":"test","severity":"Default"}` + "\n"),
+		},
+		{
+			description: "Custom Level with Default Severity",
+			options:     []Option{WithDefaultSeverity(logging.Notice)},
+			level:       logrus.Level(10),
+			output:      []byte(`{"message":"test","severity":"Notice"}` + "\n"),
+		},
+		{
+			description: "Mapped Custom Level",
+			options:     []Option{WithDefaultSeverity(logging.Notice), WithSeverityMap(map[logrus.Level]logging.Severity{logrus.Level(10): logging.Debug})},
+			level:       logrus.Level(10),
+			output:      []byte(`{"message":"test","severity":"Debug"}` + "\n"),
+		},
+		{
+			description: "Standard Level with Default Severity",
+			options:     []Option{WithDefaultSeverity(logging.Notice)},
+			level:       logrus.InfoLevel,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithContextFields(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "No Context",
+			input: func() *logrus.Entry {
+				e := logger.WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "No Fields in Context",
+			input: func() *logrus.Entry {
+				e := logger.WithContext(context.Background()).WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nil Fields in Context",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyFields, map[string]interface{}(nil))
+				e := logger.WithContext(ctx).WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Merged",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyFields, map[string]interface{}{"requestId": "abc", "userId": 42})
+				e := logger.WithContext(ctx).WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","message":"test","requestId":"abc","severity":"Info","userId":42}` + "\n"),
+		},
+		{
+			description: "Entry Fields Take Precedence",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyFields, logrus.Fields{"requestId": "abc", "userId": 42})
+				e := logger.WithContext(ctx).WithField("userId", 7)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","requestId":"abc","severity":"Info","userId":7}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New()
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithMessageTemplate(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "No Template",
+			input: func() *logrus.Entry {
+				e := logger.WithField("id", "123")
+				e.Message = "user 123 logged in"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"id":"123","message":"user 123 logged in","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Template",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"id": "123", MessageTemplateField: "user %s logged in"})
+				e.Message = "user 123 logged in"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"id":"123","message":"user 123 logged in","message_template":"user %s logged in","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Template with Data Key",
+			options:     []Option{WithDataKey("data"), WithOrderedKeys(true)},
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"id": "123", MessageTemplateField: "user %s logged in"})
+				e.Message = "user 123 logged in"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"severity":"Info","message":"user 123 logged in","message_template":"user %s logged in","data":{"id":"123"}}` + "\n"),
+		},
+		{
+			description: "Template and Message Template Field",
+			input: func() *logrus.Entry {
+				// There are enough other fields that the order of the field loop varies.
+				e := logger.WithFields(logrus.Fields{"a": 1, "b": 2, "c": 3, "d": 4, MessageTemplateKey: "field", MessageTemplateField: "user %s logged in"})
+				e.Message = "user 123 logged in"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"a":1,"b":2,"c":3,"d":4,"fields.message_template":"field","message":"user 123 logged in","message_template":"user %s logged in","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithDisableNewline(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Disabled",
+			options:     []Option{WithDisableNewline(true)},
+			output:      []byte(`{"message":"test","severity":"Info"}`),
+		},
+		{
+			description: "Disabled Ordered",
+			options:     []Option{WithDisableNewline(true), WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"test"}`),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+			assert.Equal(t, bytes.Count(row.output, []byte("\n")), bytes.Count(result, []byte("\n")))
+
+			// The same goes for the buffer that logrus provides.
+			e.Buffer = &bytes.Buffer{}
+			result, err = formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestClone(t *testing.T) {
+	t.Run("Copies", func(t *testing.T) {
+		// Leave out the functions and the sampling source, since those cannot be compared.
+		formatter := New(
+			WithLabels(map[string]string{"key": "value"}),
+			WithReportCaller(true),
+			WithProjectID("project"),
+			WithSeverityMap(map[logrus.Level]logging.Severity{logrus.InfoLevel: logging.Notice}),
+			WithLabelKeys("label"),
+			WithErrorReporting("service", "1.0.0"),
+			WithRedactKeys("password"),
+			WithValueRedaction(regexp.MustCompile(`\d+`)),
+			WithResource("global", map[string]string{"project_id": "project"}),
+			WithSampleRates(map[logrus.Level]float64{logrus.DebugLevel: 0.5}),
+			WithMinLevel(logrus.DebugLevel),
+			WithFieldMap(map[string]string{MessageKey: "msg"}),
+		)
+		clone := formatter.Clone()
+		assert.Equal(t, formatter, clone)
+		assert.NotSame(t, formatter.ErrorReporting, clone.ErrorReporting)
+		assert.NotSame(t, formatter.Resource, clone.Resource)
+
+		clone.SetSeverity(logrus.InfoLevel, logging.Warning)
+		clone.LabelKeys[0] = "other"
+		clone.Resource.Labels["project_id"] = "other"
+		clone.SampleRates[logrus.DebugLevel] = 1
+		clone.FieldMap[MessageKey] = "other"
+		assert.Equal(t, logging.Notice, formatter.SeverityMap[logrus.InfoLevel])
+		assert.Equal(t, []string{"label"}, formatter.LabelKeys)
+		assert.Equal(t, map[string]string{"project_id": "project"}, formatter.Resource.Labels)
+		assert.Equal(t, 0.5, formatter.SampleRates[logrus.DebugLevel])
+		assert.Equal(t, "msg", formatter.FieldMap[MessageKey])
+	})
+
+	t.Run("Labels", func(t *testing.T) {
+		logger := logrus.New()
+		e := logrus.NewEntry(logger)
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithLabels(map[string]string{"key": "value"}), WithDisableTimestamp(true))
+		clone := formatter.Clone()
+		clone.AddLabel("request", "1")
+		clone.RemoveLabel("key")
+
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, []byte(`{"logging.googleapis.com/labels":{"key":"value"},"message":"test","severity":"Info"}`+"\n"), result)
+		assert.Equal(t, map[string]string{"key": "value"}, formatter.Labels)
+
+		result, err = clone.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, []byte(`{"logging.googleapis.com/labels":{"request":"1"},"message":"test","severity":"Info"}`+"\n"), result)
+	})
+
+	t.Run("Zero Value", func(t *testing.T) {
+		formatter := &Formatter{}
+		clone := formatter.Clone()
+		assert.Equal(t, formatter, clone)
+	})
+}
+
+func TestFormatWithComponentField(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			fields:      logrus.Fields{"logger": "database", "key": "value"},
+			output:      []byte(`{"key":"value","logger":"database","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Promoted",
+			options:     []Option{WithComponentField("logger")},
+			fields:      logrus.Fields{"logger": "database", "key": "value"},
+			output:      []byte(`{"component":"database","key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Absent",
+			options:     []Option{WithComponentField("logger")},
+			fields:      logrus.Fields{"key": "value"},
+			output:      []byte(`{"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Data Key",
+			options:     []Option{WithComponentField("logger"), WithDataKey("data")},
+			fields:      logrus.Fields{"logger": "database", "key": "value"},
+			output:      []byte(`{"component":"database","data":{"key":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Same Field",
+			options:     []Option{WithComponentField(ComponentKey)},
+			fields:      logrus.Fields{ComponentKey: "database"},
+			output:      []byte(`{"component":"database","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithComponentField("logger"), WithOrderedKeys(true)},
+			fields:      logrus.Fields{"logger": "database", "key": "value"},
+			output:      []byte(`{"severity":"Info","message":"test","component":"database","key":"value"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithContextSeverity(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		level       logrus.Level
+		severity    interface{}
+		output      []byte
+	}{
+		{
+			description: "None",
+			level:       logrus.ErrorLevel,
+			output:      []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Severity",
+			level:       logrus.ErrorLevel,
+			severity:    logging.Info,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Name",
+			level:       logrus.InfoLevel,
+			severity:    "critical",
+			output:      []byte(`{"message":"test","severity":"Critical"}` + "\n"),
+		},
+		{
+			description: "Default Name",
+			level:       logrus.InfoLevel,
+			severity:    "Default",
+			output:      []byte(`{"message":"test","severity":"Default"}` + "\n"),
+		},
+		{
+			description: "Invalid Name",
+			level:       logrus.WarnLevel,
+			severity:    "loud",
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Invalid Severity",
+			level:       logrus.WarnLevel,
+			severity:    logging.Severity(42),
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Invalid Type",
+			level:       logrus.WarnLevel,
+			severity:    100,
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Max Severity",
+			options:     []Option{WithMaxSeverity(logging.Error)},
+			level:       logrus.InfoLevel,
+			severity:    logging.Emergency,
+			output:      []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Error Reporting",
+			options:     []Option{WithErrorReporting("service", "")},
+			level:       logrus.ErrorLevel,
+			severity:    logging.Info,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.Background()
+			if row.severity != nil {
+				ctx = context.WithValue(ctx, ContextKeySeverity, row.severity)
+			}
+			e := logger.WithContext(ctx)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithPretty(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output: []byte(`{
+  "key": "<b>",
+  "logging.googleapis.com/labels": {
+    "label": "value"
+  },
+  "message": "test",
+  "nested": {
+    "a": [
+      1,
+      2
+    ]
+  },
+  "severity": "Info"
+}` + "\n"),
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithOrderedKeys(true)},
+			output: []byte(`{
+  "severity": "Info",
+  "message": "test",
+  "logging.googleapis.com/labels": {
+    "label": "value"
+  },
+  "key": "<b>",
+  "nested": {
+    "a": [
+      1,
+      2
+    ]
+  }
+}` + "\n"),
+		},
+		{
+			description: "Disable Newline",
+			options:     []Option{WithDisableNewline(true)},
+			output: []byte(`{
+  "key": "<b>",
+  "logging.googleapis.com/labels": {
+    "label": "value"
+  },
+  "message": "test",
+  "nested": {
+    "a": [
+      1,
+      2
+    ]
+  },
+  "severity": "Info"
+}`),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(logrus.Fields{"key": "<b>", "nested": map[string]interface{}{"a": []int{1, 2}}})
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append([]Option{WithPretty(true), WithLabels(map[string]string{"label": "value"})}, row.options...)...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+
+			// The pretty output has exactly the same fields as the normal output.
+			normal, err := New(append([]Option{WithLabels(map[string]string{"label": "value"})}, row.options...)...).Format(e)
+			require.Nil(t, err)
+			var prettyOutput, normalOutput map[string]interface{}
+			require.Nil(t, json.Unmarshal(result, &prettyOutput))
+			require.Nil(t, json.Unmarshal(normal, &normalOutput))
+			assert.Equal(t, normalOutput, prettyOutput)
+		})
+	}
+}
+
+func TestFormatNilEntry(t *testing.T) {
+	formatter := New()
+	assert.NotPanics(t, func() {
+		result, err := formatter.Format(nil)
+		assert.ErrorIs(t, err, ErrNilEntry)
+		assert.Nil(t, result)
+	})
+
+	// The zero-value formatter behaves the same way.
+	assert.NotPanics(t, func() {
+		_, err := (&Formatter{}).Format(nil)
+		assert.ErrorIs(t, err, ErrNilEntry)
+	})
+}
+
+func TestBuildEntryAndMarshal(t *testing.T) {
+	logger := logrus.New()
+	newEntry := func() *logrus.Entry {
+		ctx := WithTrace(context.Background(), "105445aa7843bc8bf206b12000100000", "09158d8185d3c3af")
+		e := logger.WithContext(ctx).WithFields(logrus.Fields{"key": "value"})
+		e.Message = "test"
+		e.Level = logrus.WarnLevel
+		e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+		return e
+	}
+
+	t.Run("Keys", func(t *testing.T) {
+		formatter := New(WithLabels(map[string]string{"label": "value"}))
+		mapEntry, err := formatter.BuildEntry(newEntry())
+		require.Nil(t, err)
+		assert.Equal(t, map[string]interface{}{
+			SeverityKey:     "Warning",
+			MessageKey:      "test",
+			TimestampKey:    "2024-06-15T08:30:15Z",
+			TraceKey:        "105445aa7843bc8bf206b12000100000",
+			SpanKey:         "09158d8185d3c3af",
+			TraceSampledKey: false,
+			LabelsKey:       map[string]string{"label": "value"},
+			"key":           "value",
+		}, mapEntry)
+	})
+
+	t.Run("Composition", func(t *testing.T) {
+		for _, options := range [][]Option{nil, {WithOrderedKeys(true)}, {WithDisableNewline(true)}, {WithDataKey("data")}} {
+			formatter := New(options...)
+			expected, err := formatter.Format(newEntry())
+			require.Nil(t, err)
+
+			mapEntry, err := formatter.BuildEntry(newEntry())
+			require.Nil(t, err)
+			result, err := formatter.Marshal(mapEntry)
+			require.Nil(t, err)
+			assert.Equal(t, string(expected), string(result))
+		}
+	})
+
+	t.Run("Hook", func(t *testing.T) {
+		formatter := New(WithOrderedKeys(true))
+		mapEntry, err := formatter.BuildEntry(newEntry())
+		require.Nil(t, err)
+		mapEntry["correlation"] = fmt.Sprintf("%s/%s", mapEntry[SeverityKey], mapEntry[MessageKey])
+		result, err := formatter.Marshal(mapEntry)
+		require.Nil(t, err)
+		assert.Equal(t, `{"severity":"Warning","message":"test","time":"2024-06-15T08:30:15Z","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace_sampled":false,"correlation":"Warning/test","key":"value"}`+"\n", string(result))
+	})
+
+	t.Run("Unserializable", func(t *testing.T) {
+		formatter := New()
+		result, err := formatter.Marshal(map[string]interface{}{"channel": make(chan int)})
+		require.Nil(t, err)
+		assert.Equal(t, `{"channel":"<unserializable: chan int>"}`+"\n", string(result))
+	})
+
+	t.Run("Nil Entry", func(t *testing.T) {
+		_, err := New().BuildEntry(nil)
+		assert.ErrorIs(t, err, ErrNilEntry)
+	})
+}
+
+func TestFormatterNow(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		formatter := &Formatter{}
+		before := time.Now()
+		now := formatter.now()
+		assert.False(t, now.Before(before))
+		assert.False(t, now.After(time.Now()))
+	})
+
+	t.Run("Pinned", func(t *testing.T) {
+		pinned := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+		formatter := New()
+		formatter.nowFunc = func() time.Time {
+			return pinned
+		}
+		assert.Equal(t, pinned, formatter.now())
+		assert.Equal(t, pinned, formatter.Clone().now())
+	})
+}
@@ -0,0 +1,46 @@
+package gcfstructuredlogformatter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// GroupHashLabel is the label for the group hash (see `Formatter.GroupHash`).
+const GroupHashLabel = "group_hash"
+
+// GroupHashFunc returns the group hash for the given input.
+type GroupHashFunc func(input []byte) string
+
+// DefaultGroupHash returns the first 16 hex digits of the SHA-256 hash of the input.
+func DefaultGroupHash(input []byte) string {
+	sum := sha256.Sum256(input)
+	return hex.EncodeToString(sum[:8])
+}
+
+// groupHash returns the group hash for the entry.
+//
+// The hash is computed from the message template (see `MessageTemplateField`), or the message if there is no template,
+// followed by the `GroupHashFields` (in order) that the entry has; the other fields (such as IDs) do not affect it.
+func (f *Formatter) groupHash(entry *logrus.Entry, fields logrus.Fields) string {
+	var input strings.Builder
+	if template, okay := fields[MessageTemplateField]; okay {
+		input.WriteString(fmt.Sprint(template))
+	} else {
+		input.WriteString(entry.Message)
+	}
+	for _, key := range f.GroupHashFields {
+		if value, okay := fields[key]; okay {
+			fmt.Fprintf(&input, "\n%s=%v", key, value)
+		}
+	}
+
+	hashFunc := f.GroupHashFunc
+	if hashFunc == nil {
+		hashFunc = DefaultGroupHash
+	}
+	return hashFunc([]byte(input.String()))
+}
@@ -0,0 +1,97 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultGroupHash(t *testing.T) {
+	assert.Equal(t, "ba7816bf8f01cfea", DefaultGroupHash([]byte("abc")))
+}
+
+func TestFormatWithGroupHash(t *testing.T) {
+	logger := logrus.New()
+
+	// groupHash formats an entry and returns its group hash label.
+	groupHash := func(t *testing.T, formatter *Formatter, message string, fields logrus.Fields) string {
+		e := logger.WithFields(fields)
+		e.Message = message
+		e.Level = logrus.ErrorLevel
+
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		entry, err := ParseEntry(result)
+		require.Nil(t, err)
+		return entry.Labels[GroupHashLabel]
+	}
+
+	t.Run("Same Template", func(t *testing.T) {
+		formatter := New(WithGroupHash("code"))
+		first := groupHash(t, formatter, "order 123 failed", logrus.Fields{MessageTemplateField: "order {id} failed", "id": "123", "code": 5})
+		second := groupHash(t, formatter, "order 456 failed", logrus.Fields{MessageTemplateField: "order {id} failed", "id": "456", "code": 5})
+		assert.NotEmpty(t, first)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("Different Template", func(t *testing.T) {
+		formatter := New(WithGroupHash())
+		first := groupHash(t, formatter, "order 123 failed", logrus.Fields{MessageTemplateField: "order {id} failed"})
+		second := groupHash(t, formatter, "user 123 failed", logrus.Fields{MessageTemplateField: "user {id} failed"})
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Different Field", func(t *testing.T) {
+		formatter := New(WithGroupHash("code"))
+		first := groupHash(t, formatter, "failed", logrus.Fields{MessageTemplateField: "failed", "code": 5})
+		second := groupHash(t, formatter, "failed", logrus.Fields{MessageTemplateField: "failed", "code": 13})
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Message", func(t *testing.T) {
+		formatter := New(WithGroupHash())
+		first := groupHash(t, formatter, "failed", logrus.Fields{"id": "123"})
+		second := groupHash(t, formatter, "failed", logrus.Fields{"id": "456"})
+		assert.Equal(t, DefaultGroupHash([]byte("failed")), first)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("Custom Function", func(t *testing.T) {
+		var inputs []string
+		formatter := New(WithGroupHash("code", "missing"), WithGroupHashFunc(func(input []byte) string {
+			inputs = append(inputs, string(input))
+			return "custom"
+		}))
+		assert.Equal(t, "custom", groupHash(t, formatter, "order 123 failed", logrus.Fields{MessageTemplateField: "order {id} failed", "code": 5}))
+		assert.Equal(t, []string{"order {id} failed\ncode=5"}, inputs)
+	})
+
+	t.Run("Context Fields", func(t *testing.T) {
+		var inputs []string
+		formatter := New(WithGroupHash("code"), WithGroupHashFunc(func(input []byte) string {
+			inputs = append(inputs, string(input))
+			return "custom"
+		}))
+
+		ctx := context.WithValue(context.Background(), ContextKeyFields, logrus.Fields{MessageTemplateField: "order {id} failed", "code": 5})
+		e := logger.WithContext(ctx)
+		e.Message = "order 123 failed"
+		e.Level = logrus.ErrorLevel
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"order {id} failed\ncode=5"}, inputs)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		formatter := New()
+		assert.Equal(t, "", groupHash(t, formatter, "failed", nil))
+	})
+
+	t.Run("Static Label", func(t *testing.T) {
+		formatter := New(WithGroupHash(), WithLabels(map[string]string{GroupHashLabel: "static"}))
+		assert.Equal(t, "static", groupHash(t, formatter, "failed", nil))
+	})
+}
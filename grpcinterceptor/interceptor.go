@@ -0,0 +1,226 @@
+// Package grpcinterceptor provides gRPC server interceptors that tie logrus entries to the request.
+//
+// The interceptors attach a logrus entry with the request context to the handler's context (see `Entry`),
+// so any OpenTelemetry span or trace information in the context is picked up by the formatter.
+// If there is no OpenTelemetry span, then the trace is read from the incoming metadata (see `WithIncomingTrace`).
+// When the call completes, an entry is logged with the method, status code, and latency.
+package grpcinterceptor
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Keys for the completion log entry.
+const (
+	// MethodKey is the key for the full gRPC method name.
+	MethodKey = "grpc.method"
+	// CodeKey is the key for the gRPC status code.
+	CodeKey = "grpc.code"
+)
+
+// TraceparentMetadataKey is the metadata key for a W3C "traceparent" value.
+const TraceparentMetadataKey = "traceparent"
+
+// contextKey is the type for the context keys used by this package.
+type contextKey string
+
+// contextKeyEntry is the context key for the request's logrus entry.
+const contextKeyEntry = contextKey("entry")
+
+// Entry returns the logrus entry for the request.
+//
+// If the context did not come from one of the interceptors, then an entry from the standard logger is returned.
+// Either way, the entry carries the context.
+func Entry(ctx context.Context) *logrus.Entry {
+	if entry, okay := ctx.Value(contextKeyEntry).(*logrus.Entry); okay {
+		return entry
+	}
+	return logrus.StandardLogger().WithContext(ctx)
+}
+
+// UnaryServerInterceptor returns a unary server interceptor that logs using the given logger.
+func UnaryServerInterceptor(logger *logrus.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		ctx = WithIncomingTrace(ctx)
+		entry := logger.WithContext(ctx)
+		response, err := handler(context.WithValue(ctx, contextKeyEntry, entry), request)
+		logCompletion(ctx, entry, info.FullMethod, start, err, "finished unary call")
+		return response, err
+	}
+}
+
+// StreamServerInterceptor returns a stream server interceptor that logs using the given logger.
+func StreamServerInterceptor(logger *logrus.Logger) grpc.StreamServerInterceptor {
+	return func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		ctx := WithIncomingTrace(stream.Context())
+		entry := logger.WithContext(ctx)
+		err := handler(server, &serverStream{ServerStream: stream, ctx: context.WithValue(ctx, contextKeyEntry, entry)})
+		logCompletion(ctx, entry, info.FullMethod, start, err, "finished streaming call")
+		return err
+	}
+}
+
+// WithIncomingTrace returns a copy of the context that carries the trace from the incoming metadata
+// (see `TraceFromMetadata`), so that the formatter picks it up.
+//
+// If the context already has a valid OpenTelemetry span, or the metadata has no trace, then the context is returned as-is.
+func WithIncomingTrace(ctx context.Context) context.Context {
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	md, okay := metadata.FromIncomingContext(ctx)
+	if !okay {
+		return ctx
+	}
+	traceContext, okay := TraceFromMetadata(md)
+	if !okay {
+		return ctx
+	}
+	return context.WithValue(ctx, gcfstructuredlogformatter.ContextKeyTraceContext, traceContext)
+}
+
+// TraceFromMetadata returns the trace from gRPC metadata.
+//
+// The "traceparent" value is used first; otherwise, the "x-cloud-trace-context" value is used.
+// If neither one holds a trace, then this returns false.
+func TraceFromMetadata(md metadata.MD) (gcfstructuredlogformatter.TraceContext, bool) {
+	if values := md.Get(TraceparentMetadataKey); len(values) > 0 {
+		if traceContext, err := gcfstructuredlogformatter.TraceFromTraceparent(values[0]); err == nil {
+			return traceContext, true
+		}
+	}
+	if values := md.Get(gcfstructuredlogformatter.CloudTraceHeader); len(values) > 0 {
+		if traceID, spanID, sampled := gcfstructuredlogformatter.TraceFromCloudHeader(values[0]); traceID != "" {
+			return gcfstructuredlogformatter.TraceContext{
+				TraceID: traceID,
+				SpanID:  spanID,
+				Sampled: sampled,
+			}, true
+		}
+	}
+	return gcfstructuredlogformatter.TraceContext{}, false
+}
+
+// serverStream is a server stream with a replacement context.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the replacement context.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// logCompletion logs the completion of a call.
+func logCompletion(ctx context.Context, entry *logrus.Entry, method string, start time.Time, err error, message string) {
+	code := status.Code(err)
+
+	httpRequest := &gcfstructuredlogformatter.HTTPRequest{
+		RequestMethod: http.MethodPost,
+		RequestURL:    method,
+		Status:        HTTPStatusFromCode(code),
+		Latency:       time.Since(start),
+		Protocol:      "gRPC",
+	}
+	if p, okay := peer.FromContext(ctx); okay && p.Addr != nil {
+		httpRequest.RemoteIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(httpRequest.RemoteIP); err == nil {
+			httpRequest.RemoteIP = host
+		}
+	}
+	if md, okay := metadata.FromIncomingContext(ctx); okay {
+		if values := md.Get("user-agent"); len(values) > 0 {
+			httpRequest.UserAgent = values[0]
+		}
+	}
+
+	entry = entry.WithFields(logrus.Fields{
+		gcfstructuredlogformatter.HTTPRequestKey: httpRequest,
+		MethodKey:                                method,
+		CodeKey:                                  code.String(),
+	})
+	if err != nil {
+		entry = entry.WithError(err)
+	}
+
+	// The level is only used by logrus to decide whether or not to log; the severity is written as-is.
+	severity := SeverityForGRPCCode(code)
+	level := logrus.InfoLevel
+	switch {
+	case severity >= logging.Error:
+		level = logrus.ErrorLevel
+	case severity >= logging.Warning:
+		level = logrus.WarnLevel
+	}
+	entry = entry.WithContext(context.WithValue(entry.Context, gcfstructuredlogformatter.ContextKeySeverity, severity))
+	entry.Log(level, message)
+}
+
+// SeverityForGRPCCode returns the Google severity for a call that finished with the gRPC status code.
+//
+// Codes that are usually the client's doing (such as NotFound or InvalidArgument) are Info, codes that may need
+// attention (such as DeadlineExceeded or Unavailable) are Warning, and codes that indicate a server bug
+// (such as Internal or Unknown) are Error.
+//
+// To use this for another entry, put the severity in the entry's context:
+//
+//	ctx = context.WithValue(ctx, gcfstructuredlogformatter.ContextKeySeverity, grpcinterceptor.SeverityForGRPCCode(code))
+func SeverityForGRPCCode(code codes.Code) logging.Severity {
+	switch code {
+	case codes.OK, codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists, codes.Unauthenticated:
+		return logging.Info
+	case codes.DeadlineExceeded, codes.PermissionDenied, codes.ResourceExhausted, codes.FailedPrecondition,
+		codes.Aborted, codes.OutOfRange, codes.Unavailable:
+		return logging.Warning
+	default:
+		return logging.Error
+	}
+}
+
+// HTTPStatusFromCode returns the HTTP status code that corresponds to the gRPC status code.
+//
+// See: https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
+func HTTPStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.Canceled:
+		return 499 // Client Closed Request
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
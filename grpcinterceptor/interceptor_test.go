@@ -0,0 +1,337 @@
+package grpcinterceptor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// syncBuffer is a buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buffer.Write(p)
+}
+
+// Entries returns the decoded log entries.
+func (b *syncBuffer) Entries(t *testing.T) []map[string]interface{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(b.buffer.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		entry := map[string]interface{}{}
+		err := json.Unmarshal([]byte(line), &entry)
+		require.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+var spanContext = trace.NewSpanContext(trace.SpanContextConfig{
+	TraceID:    trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+	SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+	TraceFlags: trace.FlagsSampled,
+})
+
+// newServer starts a health server that uses the interceptors and returns a client for it.
+func newServer(t *testing.T, logger *logrus.Logger) grpc_health_v1.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+
+	server := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			// Stand in for an OpenTelemetry interceptor.
+			func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				return handler(trace.ContextWithSpanContext(ctx, spanContext), request)
+			},
+			UnaryServerInterceptor(logger),
+			// Stand in for the handler's own logging.
+			func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				Entry(ctx).Info("handling")
+				return handler(ctx, request)
+			},
+		),
+		grpc.ChainStreamInterceptor(
+			StreamServerInterceptor(logger),
+		),
+	)
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("my-service", grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(server, healthServer)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+	t.Cleanup(server.Stop)
+
+	connection, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUserAgent("test-agent"),
+	)
+	require.Nil(t, err)
+	t.Cleanup(func() {
+		_ = connection.Close()
+	})
+	return grpc_health_v1.NewHealthClient(connection)
+}
+
+func newLogger(buffer *syncBuffer) *logrus.Logger {
+	logger := logrus.New()
+	logger.SetOutput(buffer)
+	logger.SetFormatter(gcfstructuredlogformatter.New(gcfstructuredlogformatter.WithDisableTimestamp(true)))
+	return logger
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	t.Run("OK", func(t *testing.T) {
+		var buffer syncBuffer
+		client := newServer(t, newLogger(&buffer))
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "my-service"})
+		require.Nil(t, err)
+
+		entries := buffer.Entries(t)
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, "handling", entries[0][gcfstructuredlogformatter.MessageKey])
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[0][gcfstructuredlogformatter.TraceKey])
+		assert.Equal(t, "0000000000000001", entries[0][gcfstructuredlogformatter.SpanKey])
+
+		assert.Equal(t, "finished unary call", entries[1][gcfstructuredlogformatter.MessageKey])
+		assert.Equal(t, "Info", entries[1][gcfstructuredlogformatter.SeverityKey])
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[1][gcfstructuredlogformatter.TraceKey])
+		assert.Equal(t, "/grpc.health.v1.Health/Check", entries[1][MethodKey])
+		assert.Equal(t, "OK", entries[1][CodeKey])
+
+		httpRequest, okay := entries[1][gcfstructuredlogformatter.HTTPRequestKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Equal(t, "POST", httpRequest["requestMethod"])
+		assert.Equal(t, "/grpc.health.v1.Health/Check", httpRequest["requestUrl"])
+		assert.Equal(t, float64(200), httpRequest["status"])
+		assert.Equal(t, "gRPC", httpRequest["protocol"])
+		assert.Contains(t, httpRequest["userAgent"], "test-agent")
+		assert.Regexp(t, `^[0-9.e-]+s$`, httpRequest["latency"])
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var buffer syncBuffer
+		client := newServer(t, newLogger(&buffer))
+
+		_, err := client.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown-service"})
+		require.Equal(t, codes.NotFound, status.Code(err))
+
+		entries := buffer.Entries(t)
+		require.Len(t, entries, 2)
+		assert.Equal(t, "Info", entries[1][gcfstructuredlogformatter.SeverityKey])
+		assert.Equal(t, "NotFound", entries[1][CodeKey])
+		assert.Contains(t, entries[1], logrus.ErrorKey)
+
+		httpRequest, okay := entries[1][gcfstructuredlogformatter.HTTPRequestKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Equal(t, float64(404), httpRequest["status"])
+	})
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	var buffer syncBuffer
+	client := newServer(t, newLogger(&buffer))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "my-service"})
+	require.Nil(t, err)
+	response, err := stream.Recv()
+	require.Nil(t, err)
+	assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, response.Status)
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return len(buffer.Entries(t)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	entries := buffer.Entries(t)
+	assert.Equal(t, "finished streaming call", entries[0][gcfstructuredlogformatter.MessageKey])
+	assert.Equal(t, "/grpc.health.v1.Health/Watch", entries[0][MethodKey])
+	assert.Equal(t, "Canceled", entries[0][CodeKey])
+}
+
+func TestStreamServerInterceptorWithMetadataTrace(t *testing.T) {
+	var buffer syncBuffer
+	client := newServer(t, newLogger(&buffer))
+
+	ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), TraceparentMetadataKey, "00-105445aa7843bc8bf206b12000100000-0000000000000002-01"))
+	stream, err := client.Watch(ctx, &grpc_health_v1.HealthCheckRequest{Service: "my-service"})
+	require.Nil(t, err)
+	_, err = stream.Recv()
+	require.Nil(t, err)
+	cancel()
+
+	require.Eventually(t, func() bool {
+		return len(buffer.Entries(t)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	entries := buffer.Entries(t)
+	assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[0][gcfstructuredlogformatter.TraceKey])
+	assert.Equal(t, "0000000000000002", entries[0][gcfstructuredlogformatter.SpanKey])
+	assert.Equal(t, true, entries[0][gcfstructuredlogformatter.TraceSampledKey])
+}
+
+func TestTraceFromMetadata(t *testing.T) {
+	rows := []struct {
+		description string
+		md          metadata.MD
+		okay        bool
+		output      gcfstructuredlogformatter.TraceContext
+	}{
+		{
+			description: "Traceparent",
+			md:          metadata.Pairs("traceparent", "00-105445aa7843bc8bf206b12000100000-0000000000000002-01"),
+			okay:        true,
+			output:      gcfstructuredlogformatter.TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000002", Sampled: true},
+		},
+		{
+			description: "Cloud Trace Context",
+			md:          metadata.Pairs("x-cloud-trace-context", "105445aa7843bc8bf206b12000100000/3;o=1"),
+			okay:        true,
+			output:      gcfstructuredlogformatter.TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000003", Sampled: true},
+		},
+		{
+			description: "Both",
+			md: metadata.Pairs(
+				"traceparent", "00-105445aa7843bc8bf206b12000100000-0000000000000002-00",
+				"x-cloud-trace-context", "205445aa7843bc8bf206b12000100000/3;o=1",
+			),
+			okay:   true,
+			output: gcfstructuredlogformatter.TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000002"},
+		},
+		{
+			description: "Invalid Traceparent",
+			md: metadata.Pairs(
+				"traceparent", "bogus",
+				"x-cloud-trace-context", "205445aa7843bc8bf206b12000100000/3;o=0",
+			),
+			okay:   true,
+			output: gcfstructuredlogformatter.TraceContext{TraceID: "205445aa7843bc8bf206b12000100000", SpanID: "0000000000000003"},
+		},
+		{
+			description: "Missing",
+			md:          metadata.Pairs("user-agent", "test-agent"),
+		},
+		{
+			description: "Nil",
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			output, okay := TraceFromMetadata(row.md)
+			assert.Equal(t, row.okay, okay)
+			assert.Equal(t, row.output, output)
+		})
+	}
+}
+
+func TestWithIncomingTrace(t *testing.T) {
+	// format formats an entry with the context and returns the written trace.
+	format := func(t *testing.T, ctx context.Context) interface{} {
+		formatter := gcfstructuredlogformatter.New()
+		e := logrus.NewEntry(logrus.New()).WithContext(ctx)
+		e.Level = logrus.InfoLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		entry := map[string]interface{}{}
+		require.Nil(t, json.Unmarshal(result, &entry))
+		return entry[gcfstructuredlogformatter.TraceKey]
+	}
+
+	t.Run("Metadata", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-cloud-trace-context", "205445aa7843bc8bf206b12000100000/3;o=1"))
+		assert.Equal(t, "205445aa7843bc8bf206b12000100000", format(t, WithIncomingTrace(ctx)))
+	})
+
+	t.Run("Span Wins", func(t *testing.T) {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-cloud-trace-context", "205445aa7843bc8bf206b12000100000/3;o=1"))
+		ctx = trace.ContextWithSpanContext(ctx, spanContext)
+		assert.Equal(t, ctx, WithIncomingTrace(ctx))
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", format(t, WithIncomingTrace(ctx)))
+	})
+
+	t.Run("Missing Metadata", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Equal(t, ctx, WithIncomingTrace(ctx))
+		assert.Nil(t, format(t, WithIncomingTrace(ctx)))
+	})
+}
+
+func TestEntry(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+	entry := Entry(ctx)
+	assert.Equal(t, ctx, entry.Context)
+}
+
+func TestHTTPStatusFromCode(t *testing.T) {
+	assert.Equal(t, 200, HTTPStatusFromCode(codes.OK))
+	assert.Equal(t, 499, HTTPStatusFromCode(codes.Canceled))
+	assert.Equal(t, 400, HTTPStatusFromCode(codes.InvalidArgument))
+	assert.Equal(t, 404, HTTPStatusFromCode(codes.NotFound))
+	assert.Equal(t, 500, HTTPStatusFromCode(codes.Internal))
+	assert.Equal(t, 503, HTTPStatusFromCode(codes.Unavailable))
+}
+
+func TestSeverityForGRPCCode(t *testing.T) {
+	rows := []struct {
+		code     codes.Code
+		severity logging.Severity
+	}{
+		{code: codes.OK, severity: logging.Info},
+		{code: codes.Canceled, severity: logging.Info},
+		{code: codes.InvalidArgument, severity: logging.Info},
+		{code: codes.NotFound, severity: logging.Info},
+		{code: codes.Unauthenticated, severity: logging.Info},
+		{code: codes.DeadlineExceeded, severity: logging.Warning},
+		{code: codes.PermissionDenied, severity: logging.Warning},
+		{code: codes.ResourceExhausted, severity: logging.Warning},
+		{code: codes.Unavailable, severity: logging.Warning},
+		{code: codes.Unknown, severity: logging.Error},
+		{code: codes.Unimplemented, severity: logging.Error},
+		{code: codes.Internal, severity: logging.Error},
+		{code: codes.DataLoss, severity: logging.Error},
+		{code: codes.Code(100), severity: logging.Error},
+	}
+
+	for _, row := range rows {
+		t.Run(row.code.String(), func(t *testing.T) {
+			assert.Equal(t, row.severity, SeverityForGRPCCode(row.code))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+// Package httpmiddleware provides net/http middleware that ties logrus entries to the request.
+//
+// The middleware puts the incoming trace (from a "traceparent" or "X-Cloud-Trace-Context" header) on the
+// request context so that the formatter picks it up, and attaches a logrus entry with that context (see `Entry`).
+// When the request completes, an access log entry is logged in the Google Cloud "httpRequest" shape.
+package httpmiddleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// contextKey is the type for the context keys used by this package.
+type contextKey string
+
+// contextKeyEntry is the context key for the request's logrus entry.
+const contextKeyEntry = contextKey("entry")
+
+// Entry returns the logrus entry for the request.
+//
+// If the context did not come from the middleware, then an entry from the standard logger is returned.
+// Either way, the entry carries the context.
+func Entry(ctx context.Context) *logrus.Entry {
+	if entry, okay := ctx.Value(contextKeyEntry).(*logrus.Entry); okay {
+		return entry
+	}
+	return logrus.StandardLogger().WithContext(ctx)
+}
+
+// Middleware returns middleware that logs using the given logger.
+func Middleware(logger *logrus.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			ctx := traceContext(r)
+			entry := logger.WithContext(ctx)
+
+			writer := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(writer, r.WithContext(context.WithValue(ctx, contextKeyEntry, entry)))
+
+			logCompletion(entry, r, writer, start)
+		})
+	}
+}
+
+// traceContext returns the request context with the incoming trace.
+//
+// An existing OpenTelemetry span takes precedence, followed by the "traceparent" header and then
+// the "X-Cloud-Trace-Context" header.
+func traceContext(r *http.Request) context.Context {
+	ctx := r.Context()
+	if trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if extracted := (propagation.TraceContext{}).Extract(ctx, propagation.HeaderCarrier(r.Header)); trace.SpanContextFromContext(extracted).IsValid() {
+		return extracted
+	}
+	if traceID, spanID, sampled := gcfstructuredlogformatter.TraceFromCloudHeader(r.Header.Get(gcfstructuredlogformatter.CloudTraceHeader)); traceID != "" {
+		return context.WithValue(ctx, gcfstructuredlogformatter.ContextKeyTraceContext, gcfstructuredlogformatter.TraceContext{
+			TraceID: traceID,
+			SpanID:  spanID,
+			Sampled: sampled,
+		})
+	}
+	return ctx
+}
+
+// responseWriter is a response writer that records the status code and the number of bytes written.
+type responseWriter struct {
+	http.ResponseWriter
+	status       int
+	bytesWritten int64
+}
+
+// WriteHeader records the status code.
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write records the number of bytes written.
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytesWritten += int64(n)
+	return n, err
+}
+
+// Flush flushes the underlying response writer, if it supports flushing.
+func (w *responseWriter) Flush() {
+	if flusher, okay := w.ResponseWriter.(http.Flusher); okay {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer for use with `http.ResponseController`.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logCompletion logs the completion of a request.
+func logCompletion(entry *logrus.Entry, r *http.Request, w *responseWriter, start time.Time) {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	httpRequest := &gcfstructuredlogformatter.HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		Status:        status,
+		ResponseSize:  w.bytesWritten,
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      r.RemoteAddr,
+		Referer:       r.Referer(),
+		Latency:       time.Since(start),
+		Protocol:      r.Proto,
+	}
+	if r.ContentLength > 0 {
+		httpRequest.RequestSize = r.ContentLength
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		httpRequest.RemoteIP = host
+	}
+
+	level := logrus.InfoLevel
+	switch {
+	case status >= http.StatusInternalServerError:
+		level = logrus.ErrorLevel
+	case status >= http.StatusBadRequest:
+		level = logrus.WarnLevel
+	}
+	entry.WithField(gcfstructuredlogformatter.HTTPRequestKey, httpRequest).Logf(level, "%s %s", r.Method, r.URL.Path)
+}
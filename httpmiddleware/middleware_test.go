@@ -0,0 +1,107 @@
+package httpmiddleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+)
+
+// serve runs the request through the middleware and returns the decoded log entries.
+func serve(t *testing.T, request *http.Request, handler http.HandlerFunc) []map[string]interface{} {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buffer)
+	logger.SetFormatter(gcfstructuredlogformatter.New(gcfstructuredlogformatter.WithDisableTimestamp(true)))
+
+	recorder := httptest.NewRecorder()
+	Middleware(logger)(handler).ServeHTTP(recorder, request)
+
+	var entries []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		entry := map[string]interface{}{}
+		err := json.Unmarshal([]byte(line), &entry)
+		require.Nil(t, err)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("traceparent", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/path?query=1", nil)
+		request.Header.Set("traceparent", "00-105445aa7843bc8bf206b12000100000-0000000000000001-01")
+		request.Header.Set("User-Agent", "test-agent")
+		request.RemoteAddr = "192.0.2.1:1234"
+
+		entries := serve(t, request, func(w http.ResponseWriter, r *http.Request) {
+			Entry(r.Context()).Info("handling")
+			_, _ = w.Write([]byte("hello"))
+		})
+		require.Len(t, entries, 2)
+
+		assert.Equal(t, "handling", entries[0][gcfstructuredlogformatter.MessageKey])
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[0][gcfstructuredlogformatter.TraceKey])
+
+		assert.Equal(t, "GET /path", entries[1][gcfstructuredlogformatter.MessageKey])
+		assert.Equal(t, "Info", entries[1][gcfstructuredlogformatter.SeverityKey])
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[1][gcfstructuredlogformatter.TraceKey])
+		assert.Equal(t, "0000000000000001", entries[1][gcfstructuredlogformatter.SpanKey])
+		assert.Equal(t, true, entries[1][gcfstructuredlogformatter.TraceSampledKey])
+
+		httpRequest, okay := entries[1][gcfstructuredlogformatter.HTTPRequestKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Equal(t, "GET", httpRequest["requestMethod"])
+		assert.Equal(t, "/path?query=1", httpRequest["requestUrl"])
+		assert.Equal(t, float64(200), httpRequest["status"])
+		assert.Equal(t, "5", httpRequest["responseSize"])
+		assert.Equal(t, "test-agent", httpRequest["userAgent"])
+		assert.Equal(t, "192.0.2.1", httpRequest["remoteIp"])
+		assert.Equal(t, "HTTP/1.1", httpRequest["protocol"])
+		assert.Regexp(t, `^[0-9.e-]+s$`, httpRequest["latency"])
+	})
+
+	t.Run("X-Cloud-Trace-Context", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodPost, "/path", strings.NewReader("body"))
+		request.Header.Set(gcfstructuredlogformatter.CloudTraceHeader, "105445aa7843bc8bf206b12000100000/1;o=1")
+
+		entries := serve(t, request, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		require.Len(t, entries, 1)
+
+		assert.Equal(t, "Warning", entries[0][gcfstructuredlogformatter.SeverityKey])
+		assert.Equal(t, "105445aa7843bc8bf206b12000100000", entries[0][gcfstructuredlogformatter.TraceKey])
+		assert.Equal(t, "0000000000000001", entries[0][gcfstructuredlogformatter.SpanKey])
+
+		httpRequest, okay := entries[0][gcfstructuredlogformatter.HTTPRequestKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Equal(t, "POST", httpRequest["requestMethod"])
+		assert.Equal(t, "4", httpRequest["requestSize"])
+		assert.Equal(t, float64(404), httpRequest["status"])
+		assert.NotContains(t, httpRequest, "responseSize")
+	})
+
+	t.Run("No Trace", func(t *testing.T) {
+		request := httptest.NewRequest(http.MethodGet, "/path", nil)
+
+		entries := serve(t, request, func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "oops", http.StatusInternalServerError)
+		})
+		require.Len(t, entries, 1)
+
+		assert.Equal(t, "Error", entries[0][gcfstructuredlogformatter.SeverityKey])
+		assert.NotContains(t, entries[0], gcfstructuredlogformatter.TraceKey)
+
+		httpRequest, okay := entries[0][gcfstructuredlogformatter.HTTPRequestKey].(map[string]interface{})
+		require.True(t, okay)
+		assert.Equal(t, float64(500), httpRequest["status"])
+	})
+}
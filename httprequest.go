@@ -0,0 +1,77 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// HTTPRequest is information about the HTTP request associated with a log entry.
+//
+// This may be provided either through the context (see `ContextKeyHTTPRequest`) or as a logrus field named `HTTPRequestKey` (see `WithHTTPRequest`).
+//
+// See: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#HttpRequest
+type HTTPRequest struct {
+	RequestMethod string        // The request method; for example, "GET".
+	RequestURL    string        // The scheme, host, path, and query portion of the URL that was requested.
+	RequestSize   int64         // The size of the HTTP request message in bytes.
+	Status        int           // The response code indicating the status of the response.
+	ResponseSize  int64         // The size of the HTTP response message sent back to the client, in bytes.
+	UserAgent     string        // The user agent sent by the client.
+	RemoteIP      string        // The IP address of the client that issued the HTTP request.
+	ServerIP      string        // The IP address of the server that the HTTP request was sent to.
+	Referer       string        // The referer URL of the request.
+	Latency       time.Duration // The request processing latency on the server.
+	Protocol      string        // The protocol used for the request; for example, "HTTP/1.1".
+}
+
+// httpRequestJSON is the JSON representation of an `HTTPRequest`.
+type httpRequestJSON struct {
+	RequestMethod string `json:"requestMethod,omitempty"`
+	RequestURL    string `json:"requestUrl,omitempty"`
+	RequestSize   int64  `json:"requestSize,string,omitempty"`
+	Status        int    `json:"status,omitempty"`
+	ResponseSize  int64  `json:"responseSize,string,omitempty"`
+	UserAgent     string `json:"userAgent,omitempty"`
+	RemoteIP      string `json:"remoteIp,omitempty"`
+	ServerIP      string `json:"serverIp,omitempty"`
+	Referer       string `json:"referer,omitempty"`
+	Latency       string `json:"latency,omitempty"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// MarshalJSON marshals the HTTP request into the format that Google expects.
+func (r HTTPRequest) MarshalJSON() ([]byte, error) {
+	output := httpRequestJSON{
+		RequestMethod: r.RequestMethod,
+		RequestURL:    r.RequestURL,
+		RequestSize:   r.RequestSize,
+		Status:        r.Status,
+		ResponseSize:  r.ResponseSize,
+		UserAgent:     r.UserAgent,
+		RemoteIP:      r.RemoteIP,
+		ServerIP:      r.ServerIP,
+		Referer:       r.Referer,
+		Protocol:      r.Protocol,
+	}
+	if r.Latency != 0 {
+		// Google expects the latency as a duration in seconds with an "s" suffix.
+		output.Latency = strconv.FormatFloat(r.Latency.Seconds(), 'f', -1, 64) + "s"
+	}
+	// Leave any HTML escaping to the encoder that writes the entry.
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(output); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
+
+// WithHTTPRequest adds the HTTP request to the entry as a logrus field named `HTTPRequestKey`.
+func WithHTTPRequest(entry *logrus.Entry, httpRequest HTTPRequest) *logrus.Entry {
+	return entry.WithField(HTTPRequestKey, httpRequest)
+}
@@ -0,0 +1,114 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPRequestMarshalJSON(t *testing.T) {
+	rows := []struct {
+		description string
+		input       HTTPRequest
+		output      string
+	}{
+		{
+			description: "Empty",
+			input:       HTTPRequest{},
+			output:      `{}`,
+		},
+		{
+			description: "Full",
+			input: HTTPRequest{
+				RequestMethod: "GET",
+				RequestURL:    "https://example.com/path?query=1",
+				RequestSize:   12,
+				Status:        200,
+				ResponseSize:  1234,
+				UserAgent:     "test-agent",
+				RemoteIP:      "192.0.2.1",
+				ServerIP:      "192.0.2.2",
+				Referer:       "https://example.com/",
+				Latency:       123 * time.Millisecond,
+				Protocol:      "HTTP/1.1",
+			},
+			output: `{"requestMethod":"GET","requestUrl":"https://example.com/path?query=1","requestSize":"12","status":200,"responseSize":"1234","userAgent":"test-agent","remoteIp":"192.0.2.1","serverIp":"192.0.2.2","referer":"https://example.com/","latency":"0.123s","protocol":"HTTP/1.1"}`,
+		},
+		{
+			description: "Latency",
+			input: HTTPRequest{
+				Latency: 3*time.Second + 500*time.Millisecond,
+			},
+			output: `{"latency":"3.5s"}`,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			result, err := json.Marshal(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, string(result))
+		})
+	}
+}
+
+func TestFormatWithHTTPRequest(t *testing.T) {
+	logger := logrus.New()
+	httpRequest := HTTPRequest{
+		RequestMethod: "GET",
+		RequestURL:    "/path",
+		Status:        200,
+		Latency:       123 * time.Millisecond,
+	}
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Context",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyHTTPRequest, &httpRequest)
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"httpRequest":{"requestMethod":"GET","requestUrl":"/path","status":200,"latency":"0.123s"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Field",
+			input: func() *logrus.Entry {
+				e := logger.WithField(HTTPRequestKey, httpRequest)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"httpRequest":{"requestMethod":"GET","requestUrl":"/path","status":200,"latency":"0.123s"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Helper",
+			input: func() *logrus.Entry {
+				e := WithHTTPRequest(logger.WithField("key", "value"), httpRequest)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"httpRequest":{"requestMethod":"GET","requestUrl":"/path","status":200,"latency":"0.123s"},"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New()
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package gcfstructuredlogformatter
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// InsertIDFunc returns the insert ID for a log entry.
+// If it returns an empty string, then no insert ID is emitted.
+type InsertIDFunc func(entry *logrus.Entry) string
+
+// InsertIDCounter returns an `InsertIDFunc` that generates a monotonically-increasing insert ID.
+//
+// The IDs are zero-padded so that they sort lexicographically in the same order that they were generated.
+func InsertIDCounter() InsertIDFunc {
+	var counter uint64
+	return func(entry *logrus.Entry) string {
+		return fmt.Sprintf("%020d", atomic.AddUint64(&counter, 1))
+	}
+}
+
+// InsertIDFromField returns an `InsertIDFunc` that uses the value of the given logrus field.
+func InsertIDFromField(key string) InsertIDFunc {
+	return func(entry *logrus.Entry) string {
+		value, okay := entry.Data[key]
+		if !okay || value == nil {
+			return ""
+		}
+		return fmt.Sprint(value)
+	}
+}
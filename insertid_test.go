@@ -0,0 +1,82 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertIDCounter(t *testing.T) {
+	insertIDFunc := InsertIDCounter()
+	entry := logrus.NewEntry(logrus.New())
+	assert.Equal(t, "00000000000000000001", insertIDFunc(entry))
+	assert.Equal(t, "00000000000000000002", insertIDFunc(entry))
+
+	// Each counter is independent.
+	assert.Equal(t, "00000000000000000001", InsertIDCounter()(entry))
+}
+
+func TestFormatWithInsertID(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description  string
+		insertIDFunc InsertIDFunc
+		input        *logrus.Entry
+		output       []byte
+	}{
+		{
+			description: "Nil Function",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description:  "Counter",
+			insertIDFunc: InsertIDCounter(),
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/insertId":"00000000000000000001","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description:  "Field",
+			insertIDFunc: InsertIDFromField("id"),
+			input: func() *logrus.Entry {
+				e := logger.WithField("id", 1234)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"id":1234,"logging.googleapis.com/insertId":"1234","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description:  "Missing Field",
+			insertIDFunc: InsertIDFromField("id"),
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithInsertIDFunc(row.insertIDFunc))
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package gcfstructuredlogformatter
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Install sets up the logger to write Google Cloud structured logs, and returns the formatter that it installed.
+//
+// The formatter is created with the given options, the output is set to stdout (which Cloud Functions, Cloud Run,
+// and App Engine forward to Cloud Logging), and logrus's own caller reporting follows `WithReportCaller` so that
+// the source location points at the caller's code.
+// The logger's level is left as-is.
+//
+// To change the output (for example, to buffer it), call `logger.SetOutput` afterward.
+func Install(logger *logrus.Logger, opts ...Option) *Formatter {
+	formatter := New(opts...)
+	logger.SetFormatter(formatter)
+	logger.SetOutput(os.Stdout)
+	logger.SetReportCaller(formatter.ReportCaller)
+	return formatter
+}
@@ -0,0 +1,47 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstall(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		logger := logrus.New()
+		formatter := Install(logger)
+		assert.Same(t, formatter, logger.Formatter)
+		assert.Equal(t, os.Stdout, logger.Out)
+		assert.False(t, logger.ReportCaller)
+	})
+
+	t.Run("Output", func(t *testing.T) {
+		logger := logrus.New()
+		Install(logger, WithDisableTimestamp(true), WithLabels(map[string]string{"service": "api"}))
+
+		var buffer bytes.Buffer
+		logger.SetOutput(&buffer)
+		logger.WithField("key", "value").Warn("test")
+		assert.Equal(t, `{"key":"value","logging.googleapis.com/labels":{"service":"api"},"message":"test","severity":"Warning"}`+"\n", buffer.String())
+	})
+
+	t.Run("Report Caller", func(t *testing.T) {
+		logger := logrus.New()
+		Install(logger, WithReportCaller(true))
+		assert.True(t, logger.ReportCaller)
+
+		var buffer bytes.Buffer
+		logger.SetOutput(&buffer)
+		logger.Info("test")
+
+		entry, err := ParseEntry(buffer.Bytes())
+		require.Nil(t, err)
+		require.NotNil(t, entry.SourceLocation)
+		assert.Contains(t, entry.SourceLocation.File, "install_test.go")
+		assert.Contains(t, entry.SourceLocation.Function, "TestInstall")
+	})
+}
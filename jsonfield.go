@@ -0,0 +1,44 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// isJSONStringField returns true if the logrus field is one of the `ParseJSONStringFields`.
+func (f *Formatter) isJSONStringField(key string) bool {
+	for _, field := range f.ParseJSONStringFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// parseJSONString returns the value decoded from JSON if it is a string (or byte slice) that holds
+// a JSON object or array; otherwise, the value is returned as-is.
+//
+// Numbers are decoded as `json.Number` so that they keep their precision.
+func parseJSONString(value interface{}) interface{} {
+	var data []byte
+	switch value := value.(type) {
+	case string:
+		data = []byte(value)
+	case []byte:
+		data = value
+	default:
+		return value
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') || !json.Valid(trimmed) {
+		return value
+	}
+	decoder := json.NewDecoder(bytes.NewReader(trimmed))
+	decoder.UseNumber()
+	var output interface{}
+	if err := decoder.Decode(&output); err != nil {
+		return value
+	}
+	return output
+}
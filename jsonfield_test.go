@@ -0,0 +1,86 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithParseJSONStringFields(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			fields:      logrus.Fields{"payload": `{"id":1}`},
+			output:      []byte(`{"message":"test","payload":"{\"id\":1}","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Object",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": ` {"id": 12345678901234567890, "tags": ["a", "b"], "user": {"name": "<b>"}} `},
+			output:      []byte(`{"message":"test","payload":{"id":12345678901234567890,"tags":["a","b"],"user":{"name":"<b>"}},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Array",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": `[1,2]`},
+			output:      []byte(`{"message":"test","payload":[1,2],"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Bytes",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": []byte(`{"id":1}`)},
+			output:      []byte(`{"message":"test","payload":{"id":1},"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Invalid",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": `{"id":1`},
+			output:      []byte(`{"message":"test","payload":"{\"id\":1","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Scalar",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": `123`},
+			output:      []byte(`{"message":"test","payload":"123","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Not a String",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"payload": 123},
+			output:      []byte(`{"message":"test","payload":123,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Other Field",
+			options:     []Option{WithParseJSONStringFields("payload")},
+			fields:      logrus.Fields{"other": `{"id":1}`},
+			output:      []byte(`{"message":"test","other":"{\"id\":1}","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Redacted",
+			options:     []Option{WithParseJSONStringFields("payload"), WithRedactKeys("password")},
+			fields:      logrus.Fields{"payload": `{"user":"name","password":"secret"}`},
+			output:      []byte(`{"message":"test","payload":{"password":"[REDACTED]","user":"name"},"severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
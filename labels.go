@@ -0,0 +1,229 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// LabelsMode determines which key (or keys) the labels are written to.
+//
+// Cloud Logging only treats the labels as the entry's labels when they are written to `LabelsKey`;
+// the plain `PlainLabelsKey` is left in the JSON payload, which some pipelines read instead.
+type LabelsMode int
+
+const (
+	// LabelsStructured writes the labels to `LabelsKey` ("logging.googleapis.com/labels").
+	// This is the default.
+	LabelsStructured LabelsMode = iota
+	// LabelsPlain writes the labels to `PlainLabelsKey` ("labels").
+	LabelsPlain
+	// LabelsBoth writes the labels to both `LabelsKey` and `PlainLabelsKey`.
+	LabelsBoth
+	// LabelsFlat writes each label as its own top-level key, prefixed with `FlatLabelPrefix` (such as "label.foo").
+	// This saves the nested object for high-volume streams, but Cloud Logging does not treat these as the entry's labels.
+	LabelsFlat
+)
+
+// PlainLabelsKey is the key for the labels when they are written as a plain field (see `LabelsMode`).
+const PlainLabelsKey = "labels"
+
+// FlatLabelPrefix is the prefix given to each label's key when the labels are written by `LabelsFlat`.
+// With `LabelsFlat`, a logrus field whose key has this prefix is handled by the `ReservedKeyPolicy` (for example,
+// it is renamed to "fields.label.foo"), so that it cannot pass for a label.
+const FlatLabelPrefix = "label."
+
+// flatLabelKeys returns the key that `LabelsFlat` writes for each of the labels.
+func flatLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, FlatLabelPrefix+key)
+	}
+	return keys
+}
+
+// writeLabels writes the labels to the map entry, taking the `LabelsMode` into account.
+func (f *Formatter) writeLabels(mapEntry map[string]interface{}, labels map[string]string) {
+	switch f.LabelsMode {
+	case LabelsPlain:
+		mapEntry[PlainLabelsKey] = labels
+	case LabelsBoth:
+		mapEntry[LabelsKey] = labels
+		mapEntry[PlainLabelsKey] = labels
+	case LabelsFlat:
+		for key, value := range labels {
+			mapEntry[FlatLabelPrefix+key] = value
+		}
+	default:
+		mapEntry[LabelsKey] = labels
+	}
+}
+
+// LabelExtractor returns labels from the context of a log entry.
+type LabelExtractor func(ctx context.Context) map[string]string
+
+// AddLabel adds a label to the formatter.
+//
+// This is safe to call while the formatter is in use.
+func (f *Formatter) AddLabel(key, value string) {
+	f.labelsMutex.Lock()
+	defer f.labelsMutex.Unlock()
+
+	if f.Labels == nil {
+		f.Labels = map[string]string{}
+	}
+	f.Labels[key] = value
+}
+
+// RemoveLabel removes a label from the formatter.
+//
+// This is safe to call while the formatter is in use.
+func (f *Formatter) RemoveLabel(key string) {
+	f.labelsMutex.Lock()
+	defer f.labelsMutex.Unlock()
+
+	delete(f.Labels, key)
+}
+
+// DeleteLabel removes a label from the formatter; it is the same as `RemoveLabel`.
+//
+// This is safe to call while the formatter is in use.
+func (f *Formatter) DeleteLabel(key string) {
+	f.RemoveLabel(key)
+}
+
+// SetLabels replaces all of the formatter's labels with a copy of the given labels.
+// Calling this with nil removes all of the labels.
+//
+// This is safe to call while the formatter is in use; an entry is formatted with either the old or the new labels, never a mix.
+func (f *Formatter) SetLabels(labels map[string]string) {
+	labels = copyStringMap(labels)
+
+	f.labelsMutex.Lock()
+	defer f.labelsMutex.Unlock()
+
+	f.Labels = labels
+}
+
+// isLabelKey returns true if the logrus field should be promoted to a label.
+func (f *Formatter) isLabelKey(key string) bool {
+	for _, labelKey := range f.LabelKeys {
+		if labelKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// labels returns the labels for the entry.
+//
+// The labels are made up of the following, in order of increasing precedence:
+//  1. The logrus fields (including those from `ContextKeyFields`; see `entryFields`) named in `LabelKeys`.
+//  2. The OpenTelemetry baggage members named in `BaggageLabelKeys`, if the entry has a context.
+//  3. The labels from the `LabelExtractor`, if the entry has a context.
+//  4. The group hash, if `GroupHash` is set.
+//  5. The static `Labels`.
+//
+// If there are no labels, then this returns nil.
+func (f *Formatter) labels(entry *logrus.Entry, fields logrus.Fields) map[string]string {
+	var baggageLabels map[string]string
+	var extractedLabels map[string]string
+	if entry.Context != nil {
+		baggageLabels = f.baggageLabels(entry.Context)
+		if f.LabelExtractor != nil {
+			extractedLabels = f.LabelExtractor(entry.Context)
+		}
+	}
+
+	f.labelsMutex.RLock()
+	defer f.labelsMutex.RUnlock()
+
+	if len(f.LabelKeys) == 0 && len(baggageLabels) == 0 && len(extractedLabels) == 0 && !f.GroupHash && len(f.Labels) == 0 {
+		return nil
+	}
+
+	// This is a copy so that the labels can be changed while the entry is being encoded.
+	labels := make(map[string]string, len(f.LabelKeys)+len(f.Labels))
+	setLabel := func(key, value string) {
+		if f.SanitizeLabels {
+			key = sanitizeLabelKey(key)
+			if key == "" {
+				return
+			}
+		}
+		labels[key] = value
+	}
+	for _, key := range f.LabelKeys {
+		if value, okay := fields[key]; okay {
+			if stringValue, okay := value.(string); okay {
+				setLabel(key, f.redactField(key, stringValue))
+			} else {
+				setLabel(key, f.redactField(key, fmt.Sprint(value)))
+			}
+		}
+	}
+	for key, value := range baggageLabels {
+		setLabel(key, value)
+	}
+	for key, value := range extractedLabels {
+		setLabel(key, value)
+	}
+	if f.GroupHash {
+		setLabel(GroupHashLabel, f.groupHash(entry, fields))
+	}
+	for key, value := range f.Labels {
+		setLabel(key, value)
+	}
+	if len(labels) == 0 {
+		return nil
+	}
+	return labels
+}
+
+// baggageLabels returns the OpenTelemetry baggage members named in `BaggageLabelKeys`.
+//
+// If there are none, then this returns nil.
+func (f *Formatter) baggageLabels(ctx context.Context) map[string]string {
+	if len(f.BaggageLabelKeys) == 0 {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return nil
+	}
+
+	var labels map[string]string
+	for _, key := range f.BaggageLabelKeys {
+		member := bag.Member(key)
+		if member.Key() == "" {
+			continue
+		}
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[key] = member.Value()
+	}
+	return labels
+}
+
+// MaxLabelKeyBytes is the longest label key that Google Cloud Logging accepts (see `SanitizeLabels`).
+const MaxLabelKeyBytes = 512
+
+// sanitizeLabelKey returns the label key with every character other than letters, digits, "_", and "-"
+// replaced with "_", truncated to `MaxLabelKeyBytes`.
+func sanitizeLabelKey(key string) string {
+	output := make([]byte, 0, len(key))
+	for _, c := range key {
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || c == '_' || c == '-' {
+			output = append(output, byte(c))
+		} else {
+			output = append(output, '_')
+		}
+		if len(output) == MaxLabelKeyBytes {
+			break
+		}
+	}
+	return string(output)
+}
@@ -0,0 +1,621 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestFormatWithLabelKeys(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		labels      map[string]string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Missing Field",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Promoted Fields",
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"prop": "value", "tenant": "acme", "shard": 7})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"shard":"7","tenant":"acme"},"message":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Merged with Static Labels",
+			labels:      map[string]string{"service": "my-service"},
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"tenant": "acme"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"service":"my-service","tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Context Fields",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyFields, logrus.Fields{"tenant": "acme", "prop": "value"})
+				e := logger.WithContext(ctx).WithField("shard", 7)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"shard":"7","tenant":"acme"},"message":"test","prop":"value","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Entry Fields Take Precedence over Context Fields",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyFields, logrus.Fields{"tenant": "context"})
+				e := logger.WithContext(ctx).WithField("tenant", "acme")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Static Labels Take Precedence",
+			labels:      map[string]string{"tenant": "static"},
+			input: func() *logrus.Entry {
+				e := logger.WithFields(logrus.Fields{"tenant": "acme"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"tenant":"static"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithLabels(row.labels), WithLabelKeys("tenant", "shard"))
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestAddRemoveLabel(t *testing.T) {
+	formatter := New()
+	formatter.AddLabel("a", "1")
+	formatter.AddLabel("b", "2")
+	formatter.RemoveLabel("a")
+	formatter.RemoveLabel("missing")
+	assert.Equal(t, map[string]string{"b": "2"}, formatter.Labels)
+
+	// The zero value must work too.
+	var zero Formatter
+	zero.AddLabel("a", "1")
+	assert.Equal(t, map[string]string{"a": "1"}, zero.Labels)
+}
+
+func TestSetLabels(t *testing.T) {
+	labels := map[string]string{"a": "1"}
+	formatter := New(WithLabels(map[string]string{"old": "value"}))
+	formatter.SetLabels(labels)
+	assert.Equal(t, map[string]string{"a": "1"}, formatter.Labels)
+
+	// The formatter has its own copy.
+	labels["b"] = "2"
+	assert.Equal(t, map[string]string{"a": "1"}, formatter.Labels)
+
+	formatter.AddLabel("c", "3")
+	formatter.DeleteLabel("a")
+	assert.Equal(t, map[string]string{"c": "3"}, formatter.Labels)
+
+	formatter.SetLabels(nil)
+	assert.Nil(t, formatter.Labels)
+
+	e := logrus.NewEntry(logrus.New())
+	e.Level = logrus.InfoLevel
+	result, err := formatter.Format(e)
+	require.Nil(t, err)
+	assert.Equal(t, `{"message":"","severity":"Info"}`+"\n", string(result))
+}
+
+// Run this with "-race" to check for data races.
+func TestLabelsConcurrency(t *testing.T) {
+	logger := logrus.New()
+	formatter := New(WithLabels(map[string]string{"static": "value"}), WithLabelKeys("tenant"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := fmt.Sprintf("key-%d-%d", i, j%10)
+				formatter.AddLabel(key, "value")
+				formatter.DeleteLabel(key)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				e := logger.WithField("tenant", "acme")
+				e.Level = logrus.InfoLevel
+				_, err := formatter.Format(e)
+				assert.Nil(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]string{"static": "value"}, formatter.Labels)
+}
+
+// Run this with "-race" to check for data races.
+func TestSetLabelsConcurrency(t *testing.T) {
+	logger := logrus.New()
+	formatter := New(WithLabels(map[string]string{"revision": "0"}))
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			formatter.SetLabels(map[string]string{"revision": fmt.Sprint(i)})
+			formatter.AddLabel("extra", "value")
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e := logger.WithField("prop", "value")
+			e.Level = logrus.InfoLevel
+			_, err := formatter.Format(e)
+			assert.Nil(t, err)
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, map[string]string{"revision": "99", "extra": "value"}, formatter.Labels)
+}
+
+type tenantKey struct{}
+
+func TestFormatWithLabelExtractor(t *testing.T) {
+	logger := logrus.New()
+	extractor := func(ctx context.Context) map[string]string {
+		if tenant, okay := ctx.Value(tenantKey{}).(string); okay {
+			return map[string]string{"tenant_id": tenant, "service": "extracted"}
+		}
+		return nil
+	}
+	rows := []struct {
+		description string
+		labels      map[string]string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Nil Context",
+			input: func() *logrus.Entry {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "No Labels in Context",
+			input: func() *logrus.Entry {
+				e := logger.WithContext(context.Background())
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Extracted",
+			input: func() *logrus.Entry {
+				e := logger.WithContext(context.WithValue(context.Background(), tenantKey{}, "acme"))
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"service":"extracted","tenant_id":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Merged",
+			labels:      map[string]string{"service": "static", "version": "1.0.0"},
+			input: func() *logrus.Entry {
+				e := logger.WithContext(context.WithValue(context.Background(), tenantKey{}, "acme")).WithField("tenant_id", "field")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/labels":{"service":"static","tenant_id":"acme","version":"1.0.0"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithLabels(row.labels), WithLabelKeys("tenant_id"), WithLabelExtractor(extractor))
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithLabelsMode(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		labels      map[string]string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			labels:      map[string]string{"key": "value"},
+			output:      []byte(`{"logging.googleapis.com/labels":{"key":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Structured",
+			labels:      map[string]string{"key": "value"},
+			options:     []Option{WithLabelsMode(LabelsStructured)},
+			output:      []byte(`{"logging.googleapis.com/labels":{"key":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Plain",
+			labels:      map[string]string{"key": "value"},
+			options:     []Option{WithLabelsMode(LabelsPlain)},
+			output:      []byte(`{"labels":{"key":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Both",
+			labels:      map[string]string{"key": "value"},
+			options:     []Option{WithLabelsMode(LabelsBoth)},
+			output:      []byte(`{"labels":{"key":"value"},"logging.googleapis.com/labels":{"key":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Both Ordered",
+			labels:      map[string]string{"key": "value"},
+			options:     []Option{WithLabelsMode(LabelsBoth), WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"test","logging.googleapis.com/labels":{"key":"value"},"labels":{"key":"value"}}` + "\n"),
+		},
+		{
+			description: "Plain with No Labels",
+			options:     []Option{WithLabelsMode(LabelsPlain)},
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Flat",
+			labels:      map[string]string{"key": "value"},
+			options:     []Option{WithLabelsMode(LabelsFlat)},
+			output:      []byte(`{"label.key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Flat with No Labels",
+			options:     []Option{WithLabelsMode(LabelsFlat)},
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Two Labels Structured",
+			labels:      map[string]string{"a": "1", "b": "2"},
+			options:     []Option{WithLabelsMode(LabelsStructured)},
+			output:      []byte(`{"logging.googleapis.com/labels":{"a":"1","b":"2"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Two Labels Plain",
+			labels:      map[string]string{"a": "1", "b": "2"},
+			options:     []Option{WithLabelsMode(LabelsPlain)},
+			output:      []byte(`{"labels":{"a":"1","b":"2"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Two Labels Both",
+			labels:      map[string]string{"a": "1", "b": "2"},
+			options:     []Option{WithLabelsMode(LabelsBoth)},
+			output:      []byte(`{"labels":{"a":"1","b":"2"},"logging.googleapis.com/labels":{"a":"1","b":"2"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Two Labels Flat",
+			labels:      map[string]string{"a": "1", "b": "2"},
+			options:     []Option{WithLabelsMode(LabelsFlat)},
+			output:      []byte(`{"label.a":"1","label.b":"2","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Two Labels Flat Ordered",
+			labels:      map[string]string{"a": "1", "b": "2"},
+			options:     []Option{WithLabelsMode(LabelsFlat), WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"test","label.a":"1","label.b":"2"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append([]Option{WithLabels(row.labels)}, row.options...)...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithFlatLabelsOrdered(t *testing.T) {
+	e := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"alpha": "x", "zulu": "y"})
+	e.Message = "test"
+	e.Level = logrus.InfoLevel
+
+	formatter := New(WithLabelsMode(LabelsFlat), WithOrderedKeys(true), WithLabels(map[string]string{"b": "2", "a": "1"}))
+	result, err := formatter.Format(e)
+	require.Nil(t, err)
+	assert.Equal(t, `{"severity":"Info","message":"test","label.a":"1","label.b":"2","alpha":"x","zulu":"y"}`+"\n", string(result))
+}
+
+func TestFormatWithFlatLabelsAndPrefixedField(t *testing.T) {
+	rows := []struct {
+		description string
+		options     []Option
+		output      string
+	}{
+		{
+			description: "Rename",
+			output:      `{"fields.label.a":"field","fields.label.x":"field","label.a":"1","message":"test","severity":"Info"}`,
+		},
+		{
+			description: "Drop",
+			options:     []Option{WithReservedKeyPolicy(ReservedKeyDrop)},
+			output:      `{"label.a":"1","message":"test","severity":"Info"}`,
+		},
+		{
+			description: "Overwrite",
+			options:     []Option{WithReservedKeyPolicy(ReservedKeyOverwrite)},
+			output:      `{"label.a":"field","label.x":"field","message":"test","severity":"Info"}`,
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithOrderedKeys(true)},
+			output:      `{"severity":"Info","message":"test","label.a":"1","fields.label.a":"field","fields.label.x":"field"}`,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"label.x": "field", "label.a": "field"})
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append([]Option{WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"a": "1"})}, row.options...)...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output+"\n", string(result))
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		e := logrus.NewEntry(logrus.New()).WithField("label.x", "field")
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithLabelsMode(LabelsFlat), WithReservedKeyPolicy(ReservedKeyError))
+		_, err := formatter.Format(e)
+		assert.ErrorIs(t, err, ErrReservedKey)
+
+		// The prefix is only reserved for flat labels.
+		formatter = New(WithReservedKeyPolicy(ReservedKeyError))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"label.x":"field","message":"","severity":"Info"}`+"\n", string(result))
+	})
+
+	t.Run("Shrink", func(t *testing.T) {
+		e := logrus.NewEntry(logrus.New()).WithFields(logrus.Fields{"label.x": "field", "body": strings.Repeat("x", 2048)})
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"a": "1"}), WithMaxEntryBytes(1024))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"label.a":"1","message":"test`+TruncatedMarker+`","severity":"Info"}`+"\n", string(result))
+	})
+}
+
+func TestFormatWithSanitizeLabels(t *testing.T) {
+	logger := logrus.New()
+	longKey := strings.Repeat("k", MaxLabelKeyBytes+10)
+	rows := []struct {
+		description string
+		labels      map[string]string
+		fields      logrus.Fields
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			labels:      map[string]string{"my key.name": "value"},
+			output:      []byte(`{"logging.googleapis.com/labels":{"my key.name":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Spaces and Dots",
+			labels:      map[string]string{"my key.name": "value", "valid_Key-1": "other"},
+			options:     []Option{WithSanitizeLabels(true)},
+			output:      []byte(`{"logging.googleapis.com/labels":{"my_key_name":"value","valid_Key-1":"other"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Too Long",
+			labels:      map[string]string{longKey: "value"},
+			options:     []Option{WithSanitizeLabels(true)},
+			output:      []byte(`{"logging.googleapis.com/labels":{"` + longKey[:MaxLabelKeyBytes] + `":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Multibyte",
+			labels:      map[string]string{"café": "value"},
+			options:     []Option{WithSanitizeLabels(true)},
+			output:      []byte(`{"logging.googleapis.com/labels":{"caf_":"value"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty",
+			labels:      map[string]string{"": "value"},
+			options:     []Option{WithSanitizeLabels(true)},
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Label Keys",
+			fields:      logrus.Fields{"user.id": 1},
+			options:     []Option{WithSanitizeLabels(true), WithLabelKeys("user.id")},
+			output:      []byte(`{"logging.googleapis.com/labels":{"user_id":"1"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(append([]Option{WithLabels(row.labels)}, row.options...)...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithMergedLabels(t *testing.T) {
+	logger := logrus.New()
+	type tenantKey struct{}
+	extractor := func(ctx context.Context) map[string]string {
+		if tenant, okay := ctx.Value(tenantKey{}).(string); okay {
+			return map[string]string{"tenant_id": tenant}
+		}
+		return map[string]string{}
+	}
+	rows := []struct {
+		description string
+		ctx         context.Context
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Nothing to Merge",
+			ctx:         context.Background(),
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Extracted Only",
+			ctx:         context.WithValue(context.Background(), tenantKey{}, "acme"),
+			output:      []byte(`{"labels":{"tenant_id":"acme"},"logging.googleapis.com/labels":{"tenant_id":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Promoted Only",
+			ctx:         context.Background(),
+			fields:      logrus.Fields{"user_id": 1},
+			output:      []byte(`{"labels":{"user_id":"1"},"logging.googleapis.com/labels":{"user_id":"1"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithContext(row.ctx).WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			// The static labels are empty (but not nil), and the labels are written to both keys.
+			formatter := New(WithLabels(map[string]string{}), WithLabelKeys("user_id"), WithLabelExtractor(extractor), WithLabelsMode(LabelsBoth))
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithBaggageLabelKeys(t *testing.T) {
+	logger := logrus.New()
+	newBaggage := func(t *testing.T, members ...string) context.Context {
+		var list []baggage.Member
+		for i := 0; i < len(members); i += 2 {
+			member, err := baggage.NewMember(members[i], members[i+1])
+			require.Nil(t, err)
+			list = append(list, member)
+		}
+		bag, err := baggage.New(list...)
+		require.Nil(t, err)
+		return baggage.ContextWithBaggage(context.Background(), bag)
+	}
+	rows := []struct {
+		description string
+		labels      map[string]string
+		ctx         func(t *testing.T) context.Context
+		output      []byte
+	}{
+		{
+			description: "Two Members",
+			ctx: func(t *testing.T) context.Context {
+				return newBaggage(t, "tenant", "acme", "region", "us-east1", "secret", "hidden")
+			},
+			output: []byte(`{"logging.googleapis.com/labels":{"region":"us-east1","tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "One Member",
+			ctx: func(t *testing.T) context.Context {
+				return newBaggage(t, "tenant", "acme")
+			},
+			output: []byte(`{"logging.googleapis.com/labels":{"tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "No Baggage",
+			ctx: func(t *testing.T) context.Context {
+				return context.Background()
+			},
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Other Members",
+			ctx: func(t *testing.T) context.Context {
+				return newBaggage(t, "secret", "hidden")
+			},
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Static Label Wins",
+			labels:      map[string]string{"tenant": "static"},
+			ctx: func(t *testing.T) context.Context {
+				return newBaggage(t, "tenant", "acme", "region", "us-east1")
+			},
+			output: []byte(`{"logging.googleapis.com/labels":{"region":"us-east1","tenant":"static"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithContext(row.ctx(t))
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(WithLabels(row.labels), WithBaggageLabelKeys("tenant", "region"))
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// contextKeyLogger is the context key for the logger (see `ContextWithLogger`).
+const contextKeyLogger = contextKey("logger")
+
+// ContextWithLogger returns a copy of the context that carries the logger, for use with `Logger`.
+//
+// The logger should already be set up with a formatter (see `New`).
+func ContextWithLogger(ctx context.Context, logger *logrus.Logger) context.Context {
+	return context.WithValue(ctx, contextKeyLogger, logger)
+}
+
+// Logger returns an entry that carries the context, so that the formatter picks up its trace (and anything else,
+// such as `ContextKeyFields`).
+//
+// The entry comes from the logger in the context (see `ContextWithLogger`); if there is none, then it comes from
+// the standard logger.
+func Logger(ctx context.Context) *logrus.Entry {
+	logger, okay := ctx.Value(contextKeyLogger).(*logrus.Logger)
+	if !okay || logger == nil {
+		logger = logrus.StandardLogger()
+	}
+	return logger.WithContext(ctx)
+}
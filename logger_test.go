@@ -0,0 +1,73 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestLogger(t *testing.T) {
+	spanContext := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+		SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+		TraceFlags: trace.FlagsSampled,
+	})
+
+	// newLogger returns a logger that writes to the buffer using the formatter.
+	newLogger := func(buffer *bytes.Buffer) *logrus.Logger {
+		logger := logrus.New()
+		logger.SetOutput(buffer)
+		logger.SetFormatter(New(WithDisableTimestamp(true)))
+		return logger
+	}
+
+	t.Run("Span", func(t *testing.T) {
+		var buffer bytes.Buffer
+		ctx := ContextWithLogger(context.Background(), newLogger(&buffer))
+		ctx = trace.ContextWithSpanContext(ctx, spanContext)
+
+		Logger(ctx).Info("test")
+		assert.Equal(t, `{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("No Span", func(t *testing.T) {
+		var buffer bytes.Buffer
+		ctx := ContextWithLogger(context.Background(), newLogger(&buffer))
+
+		Logger(ctx).Info("test")
+		assert.Equal(t, `{"message":"test","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("Fields", func(t *testing.T) {
+		var buffer bytes.Buffer
+		ctx := ContextWithLogger(context.Background(), newLogger(&buffer))
+		ctx = context.WithValue(ctx, ContextKeyFields, logrus.Fields{"request": "1"})
+
+		Logger(ctx).WithField("key", "value").Info("test")
+		assert.Equal(t, `{"key":"value","message":"test","request":"1","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("Standard Logger", func(t *testing.T) {
+		var buffer bytes.Buffer
+		standardLogger := logrus.StandardLogger()
+		output, formatter := standardLogger.Out, standardLogger.Formatter
+		t.Cleanup(func() {
+			standardLogger.SetOutput(output)
+			standardLogger.SetFormatter(formatter)
+		})
+		standardLogger.SetOutput(&buffer)
+		standardLogger.SetFormatter(New(WithDisableTimestamp(true)))
+
+		ctx := trace.ContextWithSpanContext(context.Background(), spanContext)
+		entry := Logger(ctx)
+		assert.Same(t, standardLogger, entry.Logger)
+		assert.Equal(t, ctx, entry.Context)
+
+		entry.Info("test")
+		assert.Equal(t, `{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}`+"\n", buffer.String())
+	})
+}
@@ -0,0 +1,8 @@
+package gcfstructuredlogformatter
+
+// ValueMarshaler converts the value of a logrus field into the value that is written.
+//
+// This may be used to convert values that do not marshal well with `encoding/json` (such as protobufs)
+// or to cheaply stringify values that are expensive to marshal.
+// If it returns an error, then `Format` returns that error.
+type ValueMarshaler func(key string, value interface{}) (interface{}, error)
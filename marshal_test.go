@@ -0,0 +1,90 @@
+package gcfstructuredlogformatter
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type heavyValue struct {
+	Name string
+}
+
+func TestFormatWithValueMarshaler(t *testing.T) {
+	logger := logrus.New()
+	marshaler := func(key string, value interface{}) (interface{}, error) {
+		switch v := value.(type) {
+		case heavyValue:
+			return "heavy:" + v.Name, nil
+		case chan int:
+			return nil, errors.New("channels are not supported")
+		}
+		return value, nil
+	}
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+		err         bool
+	}{
+		{
+			description: "Unchanged",
+			input: func() *logrus.Entry {
+				e := logger.WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"value","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Converted",
+			input: func() *logrus.Entry {
+				e := logger.WithField("key", heavyValue{Name: "abc"})
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"key":"heavy:abc","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Error",
+			input: func() *logrus.Entry {
+				e := logger.WithField("key", make(chan int))
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			err: true,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithValueMarshaler(marshaler))
+			result, err := formatter.Format(row.input)
+			if row.err {
+				require.NotNil(t, err)
+				assert.Contains(t, err.Error(), "channels are not supported")
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithoutValueMarshaler(t *testing.T) {
+	logger := logrus.New()
+	e := logger.WithField("key", heavyValue{Name: "abc"})
+	e.Message = "test"
+	e.Level = logrus.InfoLevel
+
+	formatter := New()
+	result, err := formatter.Format(e)
+	require.Nil(t, err)
+	assert.Equal(t, []byte(`{"key":{"Name":"abc"},"message":"test","severity":"Info"}`+"\n"), result)
+}
@@ -0,0 +1,36 @@
+package gcfstructuredlogformatter
+
+import (
+	"strings"
+)
+
+// MultilineMode determines how line breaks in the message are written.
+type MultilineMode int
+
+const (
+	// MultilinePreserve writes the message as-is; the line breaks are escaped by JSON as usual.
+	// This is the default.
+	MultilinePreserve MultilineMode = iota
+	// MultilineEscape replaces each line break with a literal "\n" (or "\r"), so that the message is a single line of text.
+	MultilineEscape
+	// MultilineSpace removes any trailing line breaks and replaces each remaining line break with a space.
+	MultilineSpace
+)
+
+var (
+	// multilineEscapeReplacer replaces line breaks with their escape sequences.
+	multilineEscapeReplacer = strings.NewReplacer("\n", `\n`, "\r", `\r`)
+	// multilineSpaceReplacer replaces line breaks with spaces.
+	multilineSpaceReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ")
+)
+
+// formatMultiline returns the message with its line breaks handled according to the `MultilineMode`.
+func (f *Formatter) formatMultiline(message string) string {
+	switch f.MultilineMode {
+	case MultilineEscape:
+		return multilineEscapeReplacer.Replace(message)
+	case MultilineSpace:
+		return multilineSpaceReplacer.Replace(strings.TrimRight(message, "\r\n"))
+	}
+	return message
+}
@@ -0,0 +1,76 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithMultilineMode(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		message     string
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Default",
+			message:     "first\nsecond\r\nthird\n",
+			output:      []byte(`{"message":"first\nsecond\r\nthird\n","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Preserve",
+			options:     []Option{WithMultilineMode(MultilinePreserve)},
+			message:     "first\nsecond\r\nthird\n",
+			output:      []byte(`{"message":"first\nsecond\r\nthird\n","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Escape",
+			options:     []Option{WithMultilineMode(MultilineEscape)},
+			message:     "first\nsecond\r\nthird\n",
+			output:      []byte(`{"message":"first\\nsecond\\r\\nthird\\n","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Space",
+			options:     []Option{WithMultilineMode(MultilineSpace)},
+			message:     "first\nsecond\r\nthird\n",
+			output:      []byte(`{"message":"first second third","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Space with Indentation",
+			options:     []Option{WithMultilineMode(MultilineSpace)},
+			message:     "panic: failure\n\tmain.go:10",
+			output:      []byte(`{"message":"panic: failure \tmain.go:10","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Single Line",
+			options:     []Option{WithMultilineMode(MultilineEscape)},
+			message:     "test",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Fields Untouched",
+			options:     []Option{WithMultilineMode(MultilineSpace)},
+			message:     "first\nsecond",
+			fields:      logrus.Fields{"payload": "first\nsecond"},
+			output:      []byte(`{"message":"first second","payload":"first\nsecond","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = row.message
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
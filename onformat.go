@@ -0,0 +1,11 @@
+package gcfstructuredlogformatter
+
+import (
+	"cloud.google.com/go/logging"
+)
+
+// OnFormatFunc is called after an entry has been formatted with its severity and the number of bytes written
+// (for example, to count the log lines per severity).
+//
+// It is not called for an entry that is dropped or that fails to format.
+type OnFormatFunc func(severity logging.Severity, size int)
@@ -0,0 +1,105 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithOnFormat(t *testing.T) {
+	type call struct {
+		severity logging.Severity
+		size     int
+	}
+
+	t.Run("Format", func(t *testing.T) {
+		var calls []call
+		formatter := New(WithDisableTimestamp(true), WithOnFormat(func(severity logging.Severity, size int) {
+			calls = append(calls, call{severity: severity, size: size})
+		}))
+
+		e := logrus.NewEntry(logrus.New())
+		e.Message = "test"
+		e.Level = logrus.WarnLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"test","severity":"Warning"}`+"\n", string(result))
+		assert.Equal(t, []call{{severity: logging.Warning, size: len(result)}}, calls)
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var calls []call
+		var output bytes.Buffer
+		logger := logrus.New()
+		logger.SetOutput(&output)
+		logger.SetFormatter(New(WithDisableTimestamp(true), WithOnFormat(func(severity logging.Severity, size int) {
+			calls = append(calls, call{severity: severity, size: size})
+		})))
+
+		logger.Info("first")
+		logger.Error("second")
+		assert.Equal(t, []call{
+			{severity: logging.Info, size: len(`{"message":"first","severity":"Info"}` + "\n")},
+			{severity: logging.Error, size: len(`{"message":"second","severity":"Error"}` + "\n")},
+		}, calls)
+		assert.Equal(t, output.Len(), calls[0].size+calls[1].size)
+	})
+
+	t.Run("Context Severity", func(t *testing.T) {
+		var calls []call
+		formatter := New(WithOnFormat(func(severity logging.Severity, size int) {
+			calls = append(calls, call{severity: severity, size: size})
+		}))
+
+		e := logrus.NewEntry(logrus.New()).WithContext(context.WithValue(context.Background(), ContextKeySeverity, logging.Critical))
+		e.Level = logrus.InfoLevel
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		require.Len(t, calls, 1)
+		assert.Equal(t, logging.Critical, calls[0].severity)
+	})
+
+	t.Run("Dropped", func(t *testing.T) {
+		called := false
+		formatter := New(WithMinLevel(logrus.InfoLevel), WithOnFormat(func(logging.Severity, int) {
+			called = true
+		}))
+
+		e := logrus.NewEntry(logrus.New())
+		e.Level = logrus.DebugLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Empty(t, result)
+		assert.False(t, called)
+	})
+
+	t.Run("Sampled Out", func(t *testing.T) {
+		called := false
+		formatter := New(WithSampleRates(map[logrus.Level]float64{logrus.InfoLevel: 0}), WithOnFormat(func(logging.Severity, int) {
+			called = true
+		}))
+
+		e := logrus.NewEntry(logrus.New())
+		e.Level = logrus.InfoLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Empty(t, result)
+		assert.False(t, called)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		called := false
+		formatter := New(WithOnFormat(func(logging.Severity, int) {
+			called = true
+		}))
+
+		_, err := formatter.Format(nil)
+		require.NotNil(t, err)
+		assert.False(t, called)
+	})
+}
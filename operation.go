@@ -0,0 +1,22 @@
+package gcfstructuredlogformatter
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// Operation is additional information about a potentially long-running operation with which a log entry is associated.
+//
+// This may be provided either through the context (see `ContextKeyOperation`) or as a logrus field named `OperationKey` (see `WithOperation`).
+//
+// See: https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogEntryOperation
+type Operation struct {
+	ID       string `json:"id,omitempty"`       // An arbitrary operation identifier.
+	Producer string `json:"producer,omitempty"` // An arbitrary producer identifier.
+	First    bool   `json:"first,omitempty"`    // Set this to true if this is the first log entry in the operation.
+	Last     bool   `json:"last,omitempty"`     // Set this to true if this is the last log entry in the operation.
+}
+
+// WithOperation adds the operation to the entry as a logrus field named `OperationKey`.
+func WithOperation(entry *logrus.Entry, operation Operation) *logrus.Entry {
+	return entry.WithField(OperationKey, operation)
+}
@@ -0,0 +1,521 @@
+package gcfstructuredlogformatter
+
+import (
+	"math/rand"
+	"os"
+	"regexp"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// Option is an option for configuring a formatter.
+type Option func(f *Formatter)
+
+// WithLabels adds the given entry labels to the formatter.
+//
+// These are the same as `WithEntryLabels`; for the monitored resource's labels, use `WithResourceLabels`.
+func WithLabels(labels map[string]string) Option {
+	return func(f *Formatter) {
+		for key, value := range labels {
+			f.Labels[key] = value
+		}
+	}
+}
+
+// WithProjectID sets the project ID used to qualify the trace.
+func WithProjectID(projectID string) Option {
+	return func(f *Formatter) {
+		f.ProjectID = projectID
+	}
+}
+
+// WithReportCaller sets whether or not to include the source location of the caller.
+func WithReportCaller(reportCaller bool) Option {
+	return func(f *Formatter) {
+		f.ReportCaller = reportCaller
+	}
+}
+
+// WithSeverityMap overrides the Google severity for the given logrus levels.
+//
+// Levels that are not in the map keep their existing severity.
+func WithSeverityMap(severityMap map[logrus.Level]logging.Severity) Option {
+	return func(f *Formatter) {
+		for level, severity := range severityMap {
+			f.SetSeverity(level, severity)
+		}
+	}
+}
+
+// WithDisableTimestamp sets whether or not to omit the timestamp.
+func WithDisableTimestamp(disableTimestamp bool) Option {
+	return func(f *Formatter) {
+		f.DisableTimestamp = disableTimestamp
+	}
+}
+
+// WithInsertIDFunc sets the function that generates the insert ID for each entry.
+func WithInsertIDFunc(insertIDFunc InsertIDFunc) Option {
+	return func(f *Formatter) {
+		f.InsertIDFunc = insertIDFunc
+	}
+}
+
+// WithLabelKeys adds logrus fields that are moved into the labels.
+func WithLabelKeys(keys ...string) Option {
+	return func(f *Formatter) {
+		f.LabelKeys = append(f.LabelKeys, keys...)
+	}
+}
+
+// WithReservedKeyPolicy sets what happens when a logrus field collides with a key written by the formatter.
+func WithReservedKeyPolicy(policy ReservedKeyPolicy) Option {
+	return func(f *Formatter) {
+		f.ReservedKeyPolicy = policy
+	}
+}
+
+// WithErrorReporting reports entries with a severity of Error or worse to Error Reporting.
+func WithErrorReporting(service, version string) Option {
+	return func(f *Formatter) {
+		f.ErrorReporting = &ErrorReporting{
+			Service: service,
+			Version: version,
+		}
+	}
+}
+
+// WithStackTraces sets whether or not to include the stack trace of the logrus error field.
+func WithStackTraces(stackTraces bool) Option {
+	return func(f *Formatter) {
+		f.StackTraces = stackTraces
+	}
+}
+
+// WithOrderedKeys sets whether or not to write the formatter's keys first in a fixed order.
+func WithOrderedKeys(orderedKeys bool) Option {
+	return func(f *Formatter) {
+		f.OrderedKeys = orderedKeys
+	}
+}
+
+// WithMessageKey sets the key for the message; if empty, then the message is omitted (see `OmitMessage`).
+//
+// This differs from an empty `Formatter.MessageKey`, which uses `MessageKey` ("message"), so that a zero-value
+// formatter writes the message.
+func WithMessageKey(key string) Option {
+	return func(f *Formatter) {
+		if key == "" {
+			f.OmitMessage = true
+			return
+		}
+		f.MessageKey = key
+		f.OmitMessage = false
+	}
+}
+
+// WithOmitEmptyMessage sets whether or not to omit the message when it is empty.
+func WithOmitEmptyMessage(omitEmptyMessage bool) Option {
+	return func(f *Formatter) {
+		f.OmitEmptyMessage = omitEmptyMessage
+	}
+}
+
+// WithOmitMessage sets whether or not to always omit the message.
+func WithOmitMessage(omitMessage bool) Option {
+	return func(f *Formatter) {
+		f.OmitMessage = omitMessage
+	}
+}
+
+// WithDataKey nests the logrus fields under the given key instead of writing them at the top level.
+func WithDataKey(key string) Option {
+	return func(f *Formatter) {
+		f.DataKey = key
+	}
+}
+
+// WithRedactKeys adds keys whose values are replaced with `RedactedValue`.
+func WithRedactKeys(keys ...string) Option {
+	return func(f *Formatter) {
+		f.RedactKeys = append(f.RedactKeys, keys...)
+	}
+}
+
+// WithRedactSubstrings sets whether or not a key is redacted if it contains any of the redacted keys.
+func WithRedactSubstrings(redactSubstrings bool) Option {
+	return func(f *Formatter) {
+		f.RedactSubstrings = redactSubstrings
+	}
+}
+
+// WithValueRedaction adds patterns whose matches in the message and string field values are replaced with `RedactedValue`.
+//
+// Every string is checked against every pattern, so this has a cost proportional to the size of the entry.
+func WithValueRedaction(patterns ...*regexp.Regexp) Option {
+	return func(f *Formatter) {
+		f.RedactPatterns = append(f.RedactPatterns, patterns...)
+	}
+}
+
+// WithMaxFieldBytes truncates the message and string field values to the given number of bytes.
+func WithMaxFieldBytes(size int) Option {
+	return func(f *Formatter) {
+		f.MaxFieldBytes = size
+	}
+}
+
+// WithMaxEntryBytes limits the size of an entry to the given number of bytes.
+//
+// Google Cloud Logging rejects entries larger than 256KB (see `CloudLoggingMaxEntryBytes`).
+func WithMaxEntryBytes(size int) Option {
+	return func(f *Formatter) {
+		f.MaxEntryBytes = size
+	}
+}
+
+// WithLabelExtractor sets the function that adds labels from the entry's context.
+func WithLabelExtractor(labelExtractor LabelExtractor) Option {
+	return func(f *Formatter) {
+		f.LabelExtractor = labelExtractor
+	}
+}
+
+// WithFlatTraceFields sets whether or not the trace and span IDs are also written as plain fields.
+func WithFlatTraceFields(flatTraceFields bool) Option {
+	return func(f *Formatter) {
+		f.FlatTraceFields = flatTraceFields
+	}
+}
+
+// WithValueMarshaler sets the function that converts each logrus field value before it is written.
+func WithValueMarshaler(valueMarshaler ValueMarshaler) Option {
+	return func(f *Formatter) {
+		f.ValueMarshaler = valueMarshaler
+	}
+}
+
+// WithEscapeHTML sets whether or not "<", ">", and "&" are escaped in the output.
+func WithEscapeHTML(escapeHTML bool) Option {
+	return func(f *Formatter) {
+		f.EscapeHTML = escapeHTML
+	}
+}
+
+// WithMaxSeverity sets the highest severity that is written.
+func WithMaxSeverity(maxSeverity logging.Severity) Option {
+	return func(f *Formatter) {
+		f.MaxSeverity = maxSeverity
+	}
+}
+
+// WithLabelsMode sets which key (or keys) the labels are written to.
+func WithLabelsMode(labelsMode LabelsMode) Option {
+	return func(f *Formatter) {
+		f.LabelsMode = labelsMode
+	}
+}
+
+// WithResource sets the monitored resource.
+func WithResource(resourceType string, labels map[string]string) Option {
+	return func(f *Formatter) {
+		f.Resource = &Resource{
+			Type:   resourceType,
+			Labels: labels,
+		}
+	}
+}
+
+// WithMinLevel sets the least severe level that is written.
+func WithMinLevel(minLevel logrus.Level) Option {
+	return func(f *Formatter) {
+		f.MinLevel = minLevel
+		f.MinLevelSet = true
+	}
+}
+
+// WithSampleRates sets the fraction of entries to keep for each level.
+func WithSampleRates(sampleRates map[logrus.Level]float64) Option {
+	return func(f *Formatter) {
+		f.SampleRates = map[logrus.Level]float64{}
+		for level, rate := range sampleRates {
+			f.SampleRates[level] = rate
+		}
+	}
+}
+
+// WithSampleSeed seeds the random number generator that is used for sampling.
+//
+// This is mostly useful for deterministic tests.
+func WithSampleSeed(seed int64) Option {
+	return func(f *Formatter) {
+		f.sampleRand = rand.New(rand.NewSource(seed))
+	}
+}
+
+// WithDefaultSeverity sets the severity for any level that is not in the severity map.
+func WithDefaultSeverity(defaultSeverity logging.Severity) Option {
+	return func(f *Formatter) {
+		f.DefaultSeverity = defaultSeverity
+	}
+}
+
+// WithTraceKey sets the key for the trace.
+func WithTraceKey(traceKey string) Option {
+	return func(f *Formatter) {
+		f.TraceKey = traceKey
+	}
+}
+
+// WithSpanIDKey sets the key for the span ID.
+func WithSpanIDKey(spanIDKey string) Option {
+	return func(f *Formatter) {
+		f.SpanIDKey = spanIDKey
+	}
+}
+
+// WithDisableNewline sets whether or not the trailing newline is omitted.
+func WithDisableNewline(disableNewline bool) Option {
+	return func(f *Formatter) {
+		f.DisableNewline = disableNewline
+	}
+}
+
+// WithDurationFormat sets how `time.Duration` field values are written.
+func WithDurationFormat(durationFormat DurationFormat) Option {
+	return func(f *Formatter) {
+		f.DurationFormat = durationFormat
+	}
+}
+
+// WithComponentField sets the logrus field that is written as the component.
+func WithComponentField(componentField string) Option {
+	return func(f *Formatter) {
+		f.ComponentField = componentField
+	}
+}
+
+// WithSanitizeLabels sets whether or not the label keys are made valid for Google Cloud Logging.
+func WithSanitizeLabels(sanitizeLabels bool) Option {
+	return func(f *Formatter) {
+		f.SanitizeLabels = sanitizeLabels
+	}
+}
+
+// WithParseJSONStringFields adds logrus fields whose JSON string values are written as JSON.
+func WithParseJSONStringFields(keys ...string) Option {
+	return func(f *Formatter) {
+		f.ParseJSONStringFields = append(f.ParseJSONStringFields, keys...)
+	}
+}
+
+// WithMultilineMode sets how line breaks in the message are written.
+func WithMultilineMode(multilineMode MultilineMode) Option {
+	return func(f *Formatter) {
+		f.MultilineMode = multilineMode
+	}
+}
+
+// WithPretty sets whether or not the JSON is indented.
+func WithPretty(pretty bool) Option {
+	return func(f *Formatter) {
+		f.Pretty = pretty
+	}
+}
+
+// WithTraceFlagsAndState sets whether or not the OpenTelemetry trace flags and trace state are written.
+func WithTraceFlagsAndState(traceFlagsAndState bool) Option {
+	return func(f *Formatter) {
+		f.TraceFlagsAndState = traceFlagsAndState
+	}
+}
+
+// WithTimeLayout sets the layout with which `time.Time` field values are written.
+func WithTimeLayout(timeLayout string) Option {
+	return func(f *Formatter) {
+		f.TimeLayout = timeLayout
+	}
+}
+
+// WithSeverityStringer sets the function that converts the severity into the string that is written.
+func WithSeverityStringer(severityStringer SeverityStringer) Option {
+	return func(f *Formatter) {
+		f.SeverityStringer = severityStringer
+	}
+}
+
+// WithEnvLabels adds a label for each of the given environment variables that is set.
+//
+// The map is from the label key to the name of the environment variable; for example, {"service": "K_SERVICE"}.
+func WithEnvLabels(envLabels map[string]string) Option {
+	return func(f *Formatter) {
+		for key, name := range envLabels {
+			if value := os.Getenv(name); value != "" {
+				f.Labels[key] = value
+			}
+		}
+	}
+}
+
+// WithCloudRunLabels adds the "service", "revision", and "configuration" labels from the environment variables
+// that Cloud Run (and Knative) set: "K_SERVICE", "K_REVISION", and "K_CONFIGURATION".
+//
+// Any of them that is not set is left out; to read other environment variables, use `WithEnvLabels`.
+func WithCloudRunLabels() Option {
+	return WithEnvLabels(map[string]string{
+		"service":       "K_SERVICE",
+		"revision":      "K_REVISION",
+		"configuration": "K_CONFIGURATION",
+	})
+}
+
+// WithDropFunc sets the function that decides whether or not an entry is dropped.
+func WithDropFunc(dropFunc DropFunc) Option {
+	return func(f *Formatter) {
+		f.DropFunc = dropFunc
+	}
+}
+
+// WithBaggageLabelKeys adds OpenTelemetry baggage members that are copied into the labels.
+func WithBaggageLabelKeys(keys ...string) Option {
+	return func(f *Formatter) {
+		f.BaggageLabelKeys = append(f.BaggageLabelKeys, keys...)
+	}
+}
+
+// WithMaxNestingDepth sets how deeply maps and slices may be nested in a logrus field.
+func WithMaxNestingDepth(depth int) Option {
+	return func(f *Formatter) {
+		f.MaxNestingDepth = depth
+	}
+}
+
+// WithFieldMap renames the severity, message, and timestamp keys; for example, {"message": "msg", "severity": "level"}.
+//
+// This is meant for migrating from logrus's `JSONFormatter`, so that existing queries keep working.
+// Note that Google Cloud Logging only recognizes the severity and message under their default keys.
+func WithFieldMap(fieldMap map[string]string) Option {
+	return func(f *Formatter) {
+		f.FieldMap = map[string]string{}
+		for key, value := range fieldMap {
+			f.FieldMap[key] = value
+		}
+	}
+}
+
+// WithGroupHash writes a hash of the message template and the given logrus fields as the `GroupHashLabel` label.
+//
+// Entries with the same template and the same values for the given fields get the same hash, no matter what
+// their other fields (such as request IDs) are.
+func WithGroupHash(fields ...string) Option {
+	return func(f *Formatter) {
+		f.GroupHash = true
+		f.GroupHashFields = append(f.GroupHashFields, fields...)
+	}
+}
+
+// WithGroupHashFunc sets the function that computes the group hash.
+func WithGroupHashFunc(groupHashFunc GroupHashFunc) Option {
+	return func(f *Formatter) {
+		f.GroupHashFunc = groupHashFunc
+	}
+}
+
+// WithOnFormat sets the function that is called after each entry is formatted.
+func WithOnFormat(onFormat OnFormatFunc) Option {
+	return func(f *Formatter) {
+		f.OnFormat = onFormat
+	}
+}
+
+// WithTracePrecedence sets whether the OpenTelemetry span or the context keys win when both have a trace.
+func WithTracePrecedence(tracePrecedence TracePrecedence) Option {
+	return func(f *Formatter) {
+		f.TracePrecedence = tracePrecedence
+	}
+}
+
+// WithEntryLabels adds the given entry labels to the formatter; this is the same as `WithLabels`.
+func WithEntryLabels(labels map[string]string) Option {
+	return WithLabels(labels)
+}
+
+// WithResourceLabels adds the given labels to the monitored resource's labels.
+func WithResourceLabels(labels map[string]string) Option {
+	return func(f *Formatter) {
+		if f.ResourceLabels == nil {
+			f.ResourceLabels = map[string]string{}
+		}
+		for key, value := range labels {
+			f.ResourceLabels[key] = value
+		}
+	}
+}
+
+// WithDedup drops an entry with the same key as one written less than the given window ago.
+//
+// The next entry with that key that is written after the window has the number of entries that were dropped
+// as `RepeatedKey` (for example, "repeated": 1423).
+// If the flood stops instead, then the count is written in a summary entry (the dropped entry's level and message, with
+// `RepeatedKey`) ahead of the next entry that is formatted after the window closes, whatever its key; there is no timer,
+// so nothing is written until then.
+// With `DisableNewline`, no summaries are written, since each write can only have one entry.
+func WithDedup(window time.Duration) Option {
+	return func(f *Formatter) {
+		f.DedupWindow = window
+	}
+}
+
+// WithDedupKeyFunc sets the function that returns the key by which duplicate entries are recognized.
+func WithDedupKeyFunc(dedupKeyFunc DedupKeyFunc) Option {
+	return func(f *Formatter) {
+		f.DedupKeyFunc = dedupKeyFunc
+	}
+}
+
+// WithRetentionKey sets the key for the retention hint.
+func WithRetentionKey(retentionKey string) Option {
+	return func(f *Formatter) {
+		f.RetentionKey = retentionKey
+	}
+}
+
+// WithDefaultRetentionDays sets the retention hint for entries without a `RetentionField`.
+func WithDefaultRetentionDays(days int) Option {
+	return func(f *Formatter) {
+		f.DefaultRetentionDays = days
+	}
+}
+
+// WithProtoJSON sets whether or not logrus field values that are protobuf messages are written with `protojson`.
+func WithProtoJSON(protoJSON bool) Option {
+	return func(f *Formatter) {
+		f.ProtoJSON = protoJSON
+	}
+}
+
+// WithOnOversized sets the function that is called for each entry that is larger than the given size.
+//
+// If the size is not positive, then `MaxEntryBytes` (if set) or `CloudLoggingMaxEntryBytes` is used.
+func WithOnOversized(size int, onOversized OversizedFunc) Option {
+	return func(f *Formatter) {
+		f.OversizedBytes = size
+		f.OnOversized = onOversized
+	}
+}
+
+// WithSpanIDMode sets what happens to a span ID that is not 16 hexadecimal characters.
+func WithSpanIDMode(spanIDMode SpanIDMode) Option {
+	return func(f *Formatter) {
+		f.SpanIDMode = spanIDMode
+	}
+}
+
+// WithMarshalFallback sets whether or not an entry that cannot be marshaled is written as a minimal line instead of returning an error.
+func WithMarshalFallback(marshalFallback bool) Option {
+	return func(f *Formatter) {
+		f.MarshalFallback = marshalFallback
+	}
+}
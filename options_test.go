@@ -0,0 +1,444 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewWithOptions(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		formatter := New()
+		assert.Equal(t, map[string]string{}, formatter.Labels)
+		assert.Equal(t, "", formatter.ProjectID)
+		assert.False(t, formatter.FlatTraceFields)
+		assert.Nil(t, formatter.ValueMarshaler)
+		assert.False(t, formatter.EscapeHTML)
+		assert.Equal(t, logging.Default, formatter.MaxSeverity)
+		assert.Equal(t, LabelsStructured, formatter.LabelsMode)
+		assert.Nil(t, formatter.Resource)
+		assert.Equal(t, logrus.PanicLevel, formatter.MinLevel)
+		assert.False(t, formatter.MinLevelSet)
+		assert.Nil(t, formatter.SampleRates)
+		assert.Nil(t, formatter.sampleRand)
+		assert.Equal(t, logging.Default, formatter.DefaultSeverity)
+		assert.Equal(t, "", formatter.TraceKey)
+		assert.Equal(t, "", formatter.SpanIDKey)
+		assert.False(t, formatter.DisableNewline)
+		assert.Equal(t, DurationNanoseconds, formatter.DurationFormat)
+		assert.Equal(t, "", formatter.ComponentField)
+		assert.False(t, formatter.SanitizeLabels)
+		assert.Nil(t, formatter.ParseJSONStringFields)
+		assert.Equal(t, MultilinePreserve, formatter.MultilineMode)
+		assert.False(t, formatter.Pretty)
+		assert.False(t, formatter.TraceFlagsAndState)
+		assert.Equal(t, "", formatter.TimeLayout)
+		assert.Nil(t, formatter.SeverityStringer)
+		assert.Nil(t, formatter.DropFunc)
+		assert.Nil(t, formatter.BaggageLabelKeys)
+		assert.Equal(t, 0, formatter.MaxNestingDepth)
+		assert.Nil(t, formatter.FieldMap)
+		assert.False(t, formatter.GroupHash)
+		assert.Nil(t, formatter.GroupHashFields)
+		assert.Nil(t, formatter.GroupHashFunc)
+		assert.Nil(t, formatter.OnFormat)
+		assert.Equal(t, PreferOtel, formatter.TracePrecedence)
+		assert.Nil(t, formatter.ResourceLabels)
+		assert.Equal(t, time.Duration(0), formatter.DedupWindow)
+		assert.Nil(t, formatter.DedupKeyFunc)
+		assert.Equal(t, "", formatter.RetentionKey)
+		assert.Equal(t, 0, formatter.DefaultRetentionDays)
+		assert.False(t, formatter.ProtoJSON)
+		assert.Equal(t, 0, formatter.OversizedBytes)
+		assert.Nil(t, formatter.OnOversized)
+		assert.Equal(t, SpanIDPassthrough, formatter.SpanIDMode)
+		assert.False(t, formatter.MarshalFallback)
+		assert.False(t, formatter.ReportCaller)
+		assert.False(t, formatter.DisableTimestamp)
+		assert.Nil(t, formatter.InsertIDFunc)
+		assert.Nil(t, formatter.LabelKeys)
+		assert.Nil(t, formatter.LabelExtractor)
+		assert.Equal(t, ReservedKeyRename, formatter.ReservedKeyPolicy)
+		assert.Nil(t, formatter.ErrorReporting)
+		assert.False(t, formatter.StackTraces)
+		assert.False(t, formatter.OrderedKeys)
+		assert.Equal(t, MessageKey, formatter.MessageKey)
+		assert.False(t, formatter.OmitEmptyMessage)
+		assert.False(t, formatter.OmitMessage)
+		assert.Equal(t, "", formatter.DataKey)
+		assert.Nil(t, formatter.RedactKeys)
+		assert.False(t, formatter.RedactSubstrings)
+		assert.Nil(t, formatter.RedactPatterns)
+		assert.Equal(t, 0, formatter.MaxFieldBytes)
+		assert.Equal(t, 0, formatter.MaxEntryBytes)
+		assert.Equal(t, logrusToGoogleSeverityMap, formatter.SeverityMap)
+	})
+
+	t.Run("WithLabels", func(t *testing.T) {
+		labels := map[string]string{"key": "value"}
+		formatter := New(WithLabels(labels), WithLabels(map[string]string{"other": "thing"}))
+		assert.Equal(t, map[string]string{"key": "value", "other": "thing"}, formatter.Labels)
+
+		// The formatter must not share the caller's map.
+		labels["key"] = "changed"
+		assert.Equal(t, "value", formatter.Labels["key"])
+	})
+
+	t.Run("WithProjectID", func(t *testing.T) {
+		formatter := New(WithProjectID("my-project"))
+		assert.Equal(t, "my-project", formatter.ProjectID)
+	})
+
+	t.Run("WithReportCaller", func(t *testing.T) {
+		formatter := New(WithReportCaller(true))
+		assert.True(t, formatter.ReportCaller)
+	})
+
+	t.Run("WithSeverityMap", func(t *testing.T) {
+		formatter := New(WithSeverityMap(map[logrus.Level]logging.Severity{logrus.WarnLevel: logging.Notice}))
+		for level, severity := range logrusToGoogleSeverityMap {
+			if level == logrus.WarnLevel {
+				assert.Equal(t, logging.Notice, formatter.SeverityMap[level])
+			} else {
+				assert.Equal(t, severity, formatter.SeverityMap[level])
+			}
+		}
+	})
+
+	t.Run("WithDisableTimestamp", func(t *testing.T) {
+		formatter := New(WithDisableTimestamp(true))
+		assert.True(t, formatter.DisableTimestamp)
+	})
+
+	t.Run("WithInsertIDFunc", func(t *testing.T) {
+		formatter := New(WithInsertIDFunc(InsertIDCounter()))
+		assert.NotNil(t, formatter.InsertIDFunc)
+	})
+
+	t.Run("WithLabelKeys", func(t *testing.T) {
+		formatter := New(WithLabelKeys("a", "b"), WithLabelKeys("c"))
+		assert.Equal(t, []string{"a", "b", "c"}, formatter.LabelKeys)
+	})
+
+	t.Run("WithReservedKeyPolicy", func(t *testing.T) {
+		formatter := New(WithReservedKeyPolicy(ReservedKeyDrop))
+		assert.Equal(t, ReservedKeyDrop, formatter.ReservedKeyPolicy)
+
+		formatter = New(WithReservedKeyPolicy(ReservedKeyError))
+		assert.Equal(t, ReservedKeyError, formatter.ReservedKeyPolicy)
+	})
+
+	t.Run("WithErrorReporting", func(t *testing.T) {
+		formatter := New(WithErrorReporting("my-service", "1.0.0"))
+		assert.Equal(t, &ErrorReporting{Service: "my-service", Version: "1.0.0"}, formatter.ErrorReporting)
+	})
+
+	t.Run("WithStackTraces", func(t *testing.T) {
+		formatter := New(WithStackTraces(true))
+		assert.True(t, formatter.StackTraces)
+	})
+
+	t.Run("WithOrderedKeys", func(t *testing.T) {
+		formatter := New(WithOrderedKeys(true))
+		assert.True(t, formatter.OrderedKeys)
+	})
+
+	t.Run("WithMessageKey", func(t *testing.T) {
+		formatter := New(WithMessageKey("msg"))
+		assert.Equal(t, "msg", formatter.MessageKey)
+		assert.False(t, formatter.OmitMessage)
+
+		formatter = New(WithMessageKey(""))
+		assert.Equal(t, MessageKey, formatter.MessageKey)
+		assert.True(t, formatter.OmitMessage)
+	})
+
+	t.Run("WithOmitEmptyMessage", func(t *testing.T) {
+		formatter := New(WithOmitEmptyMessage(true))
+		assert.True(t, formatter.OmitEmptyMessage)
+	})
+
+	t.Run("WithOmitMessage", func(t *testing.T) {
+		formatter := New(WithOmitMessage(true))
+		assert.True(t, formatter.OmitMessage)
+	})
+
+	t.Run("WithDataKey", func(t *testing.T) {
+		formatter := New(WithDataKey("data"))
+		assert.Equal(t, "data", formatter.DataKey)
+	})
+
+	t.Run("WithRedactKeys", func(t *testing.T) {
+		formatter := New(WithRedactKeys("password"), WithRedactKeys("token"), WithRedactSubstrings(true))
+		assert.Equal(t, []string{"password", "token"}, formatter.RedactKeys)
+		assert.True(t, formatter.RedactSubstrings)
+	})
+
+	t.Run("WithValueRedaction", func(t *testing.T) {
+		pattern := regexp.MustCompile(`\d+`)
+		formatter := New(WithValueRedaction(pattern))
+		assert.Equal(t, []*regexp.Regexp{pattern}, formatter.RedactPatterns)
+	})
+
+	t.Run("WithMaxFieldBytes", func(t *testing.T) {
+		formatter := New(WithMaxFieldBytes(1024))
+		assert.Equal(t, 1024, formatter.MaxFieldBytes)
+	})
+
+	t.Run("WithMaxEntryBytes", func(t *testing.T) {
+		formatter := New(WithMaxEntryBytes(256 * 1024))
+		assert.Equal(t, 256*1024, formatter.MaxEntryBytes)
+	})
+
+	t.Run("WithLabelExtractor", func(t *testing.T) {
+		formatter := New(WithLabelExtractor(func(ctx context.Context) map[string]string { return nil }))
+		assert.NotNil(t, formatter.LabelExtractor)
+	})
+
+	t.Run("WithFlatTraceFields", func(t *testing.T) {
+		formatter := New(WithFlatTraceFields(true))
+		assert.True(t, formatter.FlatTraceFields)
+	})
+
+	t.Run("WithValueMarshaler", func(t *testing.T) {
+		formatter := New(WithValueMarshaler(func(key string, value interface{}) (interface{}, error) { return value, nil }))
+		assert.NotNil(t, formatter.ValueMarshaler)
+	})
+
+	t.Run("WithEscapeHTML", func(t *testing.T) {
+		formatter := New(WithEscapeHTML(true))
+		assert.True(t, formatter.EscapeHTML)
+	})
+
+	t.Run("WithMaxSeverity", func(t *testing.T) {
+		formatter := New(WithMaxSeverity(logging.Critical))
+		assert.Equal(t, logging.Critical, formatter.MaxSeverity)
+	})
+
+	t.Run("WithLabelsMode", func(t *testing.T) {
+		formatter := New(WithLabelsMode(LabelsBoth))
+		assert.Equal(t, LabelsBoth, formatter.LabelsMode)
+	})
+
+	t.Run("WithResource", func(t *testing.T) {
+		formatter := New(WithResource("gce_instance", map[string]string{"instance_id": "123"}))
+		assert.Equal(t, &Resource{Type: "gce_instance", Labels: map[string]string{"instance_id": "123"}}, formatter.Resource)
+	})
+
+	t.Run("WithMinLevel", func(t *testing.T) {
+		formatter := New(WithMinLevel(logrus.InfoLevel))
+		assert.Equal(t, logrus.InfoLevel, formatter.MinLevel)
+		assert.True(t, formatter.MinLevelSet)
+	})
+
+	t.Run("WithSampleRates", func(t *testing.T) {
+		formatter := New(WithSampleRates(map[logrus.Level]float64{logrus.DebugLevel: 0.1}))
+		assert.Equal(t, map[logrus.Level]float64{logrus.DebugLevel: 0.1}, formatter.SampleRates)
+	})
+	t.Run("WithSampleSeed", func(t *testing.T) {
+		formatter := New(WithSampleSeed(1))
+		assert.NotNil(t, formatter.sampleRand)
+	})
+
+	t.Run("WithDefaultSeverity", func(t *testing.T) {
+		formatter := New(WithDefaultSeverity(logging.Notice))
+		assert.Equal(t, logging.Notice, formatter.DefaultSeverity)
+	})
+
+	t.Run("WithTraceKey", func(t *testing.T) {
+		formatter := New(WithTraceKey("trace"))
+		assert.Equal(t, "trace", formatter.TraceKey)
+	})
+	t.Run("WithSpanIDKey", func(t *testing.T) {
+		formatter := New(WithSpanIDKey("span"))
+		assert.Equal(t, "span", formatter.SpanIDKey)
+	})
+
+	t.Run("WithDisableNewline", func(t *testing.T) {
+		formatter := New(WithDisableNewline(true))
+		assert.True(t, formatter.DisableNewline)
+	})
+
+	t.Run("WithDurationFormat", func(t *testing.T) {
+		formatter := New(WithDurationFormat(DurationString))
+		assert.Equal(t, DurationString, formatter.DurationFormat)
+	})
+
+	t.Run("WithComponentField", func(t *testing.T) {
+		formatter := New(WithComponentField("component"))
+		assert.Equal(t, "component", formatter.ComponentField)
+	})
+
+	t.Run("WithSanitizeLabels", func(t *testing.T) {
+		formatter := New(WithSanitizeLabels(true))
+		assert.True(t, formatter.SanitizeLabels)
+	})
+
+	t.Run("WithParseJSONStringFields", func(t *testing.T) {
+		formatter := New(WithParseJSONStringFields("payload", "response"))
+		assert.Equal(t, []string{"payload", "response"}, formatter.ParseJSONStringFields)
+	})
+
+	t.Run("WithMultilineMode", func(t *testing.T) {
+		formatter := New(WithMultilineMode(MultilineEscape))
+		assert.Equal(t, MultilineEscape, formatter.MultilineMode)
+	})
+
+	t.Run("WithPretty", func(t *testing.T) {
+		formatter := New(WithPretty(true))
+		assert.True(t, formatter.Pretty)
+	})
+
+	t.Run("WithTraceFlagsAndState", func(t *testing.T) {
+		formatter := New(WithTraceFlagsAndState(true))
+		assert.True(t, formatter.TraceFlagsAndState)
+	})
+
+	t.Run("WithTimeLayout", func(t *testing.T) {
+		formatter := New(WithTimeLayout(time.RFC3339Nano))
+		assert.Equal(t, time.RFC3339Nano, formatter.TimeLayout)
+	})
+
+	t.Run("WithSeverityStringer", func(t *testing.T) {
+		formatter := New(WithSeverityStringer(UppercaseSeverity))
+		assert.NotNil(t, formatter.SeverityStringer)
+		assert.Equal(t, "WARNING", formatter.SeverityStringer(logging.Warning))
+	})
+
+	t.Run("WithDropFunc", func(t *testing.T) {
+		formatter := New(WithDropFunc(func(*logrus.Entry) bool { return true }))
+		assert.NotNil(t, formatter.DropFunc)
+	})
+
+	t.Run("WithBaggageLabelKeys", func(t *testing.T) {
+		formatter := New(WithBaggageLabelKeys("tenant"), WithBaggageLabelKeys("region"))
+		assert.Equal(t, []string{"tenant", "region"}, formatter.BaggageLabelKeys)
+	})
+
+	t.Run("WithMaxNestingDepth", func(t *testing.T) {
+		formatter := New(WithMaxNestingDepth(2))
+		assert.Equal(t, 2, formatter.MaxNestingDepth)
+	})
+
+	t.Run("WithFieldMap", func(t *testing.T) {
+		fieldMap := map[string]string{MessageKey: "msg"}
+		formatter := New(WithFieldMap(fieldMap))
+		fieldMap[SeverityKey] = "level"
+		assert.Equal(t, map[string]string{MessageKey: "msg"}, formatter.FieldMap)
+	})
+
+	t.Run("WithGroupHash", func(t *testing.T) {
+		formatter := New(WithGroupHash("code"), WithGroupHash("service"))
+		assert.True(t, formatter.GroupHash)
+		assert.Equal(t, []string{"code", "service"}, formatter.GroupHashFields)
+	})
+
+	t.Run("WithGroupHashFunc", func(t *testing.T) {
+		formatter := New(WithGroupHashFunc(func([]byte) string { return "hash" }))
+		assert.NotNil(t, formatter.GroupHashFunc)
+	})
+
+	t.Run("WithOnFormat", func(t *testing.T) {
+		formatter := New(WithOnFormat(func(logging.Severity, int) {}))
+		assert.NotNil(t, formatter.OnFormat)
+	})
+
+	t.Run("WithTracePrecedence", func(t *testing.T) {
+		formatter := New(WithTracePrecedence(PreferContextKey))
+		assert.Equal(t, PreferContextKey, formatter.TracePrecedence)
+	})
+
+	t.Run("WithEntryLabels", func(t *testing.T) {
+		formatter := New(WithEntryLabels(map[string]string{"key": "value"}))
+		assert.Equal(t, map[string]string{"key": "value"}, formatter.Labels)
+		assert.Nil(t, formatter.ResourceLabels)
+	})
+
+	t.Run("WithResourceLabels", func(t *testing.T) {
+		formatter := New(WithResourceLabels(map[string]string{"zone": "us-east1-b"}), WithResourceLabels(map[string]string{"instance_id": "1"}))
+		assert.Equal(t, map[string]string{"zone": "us-east1-b", "instance_id": "1"}, formatter.ResourceLabels)
+		assert.Equal(t, map[string]string{}, formatter.Labels)
+	})
+
+	t.Run("WithDedup", func(t *testing.T) {
+		formatter := New(WithDedup(time.Minute))
+		assert.Equal(t, time.Minute, formatter.DedupWindow)
+	})
+
+	t.Run("WithDedupKeyFunc", func(t *testing.T) {
+		formatter := New(WithDedupKeyFunc(DefaultDedupKey))
+		assert.NotNil(t, formatter.DedupKeyFunc)
+	})
+
+	t.Run("WithRetentionKey", func(t *testing.T) {
+		formatter := New(WithRetentionKey("ttl"))
+		assert.Equal(t, "ttl", formatter.RetentionKey)
+	})
+
+	t.Run("WithDefaultRetentionDays", func(t *testing.T) {
+		formatter := New(WithDefaultRetentionDays(7))
+		assert.Equal(t, 7, formatter.DefaultRetentionDays)
+	})
+
+	t.Run("WithProtoJSON", func(t *testing.T) {
+		formatter := New(WithProtoJSON(true))
+		assert.True(t, formatter.ProtoJSON)
+	})
+
+	t.Run("WithOnOversized", func(t *testing.T) {
+		formatter := New(WithOnOversized(1024, func(int, *logrus.Entry) {}))
+		assert.Equal(t, 1024, formatter.OversizedBytes)
+		assert.NotNil(t, formatter.OnOversized)
+	})
+
+	t.Run("WithSpanIDMode", func(t *testing.T) {
+		formatter := New(WithSpanIDMode(SpanIDPad))
+		assert.Equal(t, SpanIDPad, formatter.SpanIDMode)
+	})
+
+	t.Run("WithMarshalFallback", func(t *testing.T) {
+		formatter := New(WithMarshalFallback(true))
+		assert.True(t, formatter.MarshalFallback)
+	})
+
+	t.Run("WithEnvLabels", func(t *testing.T) {
+		t.Setenv("TEST_SERVICE", "api")
+		t.Setenv("TEST_EMPTY", "")
+		formatter := New(WithEnvLabels(map[string]string{"service": "TEST_SERVICE", "empty": "TEST_EMPTY", "missing": "TEST_MISSING"}))
+		assert.Equal(t, map[string]string{"service": "api"}, formatter.Labels)
+	})
+
+	t.Run("WithCloudRunLabels", func(t *testing.T) {
+		t.Run("Set", func(t *testing.T) {
+			t.Setenv("K_SERVICE", "api")
+			t.Setenv("K_REVISION", "api-00001-abc")
+			t.Setenv("K_CONFIGURATION", "api")
+			formatter := New(WithLabels(map[string]string{"key": "value"}), WithCloudRunLabels())
+			assert.Equal(t, map[string]string{"key": "value", "service": "api", "revision": "api-00001-abc", "configuration": "api"}, formatter.Labels)
+		})
+
+		t.Run("Partly Set", func(t *testing.T) {
+			t.Setenv("K_SERVICE", "api")
+			t.Setenv("K_REVISION", "")
+			t.Setenv("K_CONFIGURATION", "")
+			formatter := New(WithCloudRunLabels())
+			assert.Equal(t, map[string]string{"service": "api"}, formatter.Labels)
+		})
+
+		t.Run("Unset", func(t *testing.T) {
+			for _, name := range []string{"K_SERVICE", "K_REVISION", "K_CONFIGURATION"} {
+				t.Setenv(name, "") // This restores the variable afterward.
+				require.Nil(t, os.Unsetenv(name))
+			}
+			formatter := New(WithCloudRunLabels())
+			assert.Equal(t, map[string]string{}, formatter.Labels)
+		})
+	})
+}
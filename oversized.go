@@ -0,0 +1,25 @@
+package gcfstructuredlogformatter
+
+import (
+	"github.com/sirupsen/logrus"
+)
+
+// CloudLoggingMaxEntryBytes is the largest entry that Google Cloud Logging accepts; larger entries are dropped.
+const CloudLoggingMaxEntryBytes = 256 * 1024
+
+// OversizedFunc is called with the size of an entry that is larger than the formatter's threshold
+// (see `Formatter.OversizedBytes`), so that it can be counted or alerted on.
+//
+// The size is that of the entry before it was shrunk to fit `Formatter.MaxEntryBytes`, if it was.
+type OversizedFunc func(size int, entry *logrus.Entry)
+
+// oversizedBytes returns the size above which an entry is oversized.
+func (f *Formatter) oversizedBytes() int {
+	if f.OversizedBytes > 0 {
+		return f.OversizedBytes
+	}
+	if f.MaxEntryBytes > 0 {
+		return f.MaxEntryBytes
+	}
+	return CloudLoggingMaxEntryBytes
+}
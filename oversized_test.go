@@ -0,0 +1,81 @@
+package gcfstructuredlogformatter
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithOnOversized(t *testing.T) {
+	logger := logrus.New()
+
+	type call struct {
+		size  int
+		entry *logrus.Entry
+	}
+
+	t.Run("Huge Field", func(t *testing.T) {
+		var calls []call
+		formatter := New(WithOnOversized(0, func(size int, entry *logrus.Entry) {
+			calls = append(calls, call{size: size, entry: entry})
+		}))
+
+		e := logger.WithField("payload", strings.Repeat("x", CloudLoggingMaxEntryBytes))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		require.Len(t, calls, 1)
+		assert.Equal(t, len(result), calls[0].size)
+		assert.Greater(t, calls[0].size, CloudLoggingMaxEntryBytes)
+		assert.Same(t, e, calls[0].entry)
+	})
+
+	t.Run("Small Entry", func(t *testing.T) {
+		called := false
+		formatter := New(WithOnOversized(0, func(int, *logrus.Entry) {
+			called = true
+		}))
+
+		e := logger.WithField("payload", "small")
+		e.Level = logrus.InfoLevel
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.False(t, called)
+	})
+
+	t.Run("Threshold", func(t *testing.T) {
+		var sizes []int
+		formatter := New(WithDisableTimestamp(true), WithOnOversized(40, func(size int, _ *logrus.Entry) {
+			sizes = append(sizes, size)
+		}))
+
+		for _, message := range []string{"short", "a message that is long enough"} {
+			e := logrus.NewEntry(logger)
+			e.Message = message
+			e.Level = logrus.InfoLevel
+			_, err := formatter.Format(e)
+			require.Nil(t, err)
+		}
+		assert.Equal(t, []int{len(`{"message":"a message that is long enough","severity":"Info"}` + "\n")}, sizes)
+	})
+
+	t.Run("Shrunk", func(t *testing.T) {
+		var sizes []int
+		formatter := New(WithMaxEntryBytes(100), WithOnOversized(0, func(size int, _ *logrus.Entry) {
+			sizes = append(sizes, size)
+		}))
+
+		e := logger.WithField("payload", strings.Repeat("x", 200))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"test…(truncated)","severity":"Info"}`+"\n", string(result))
+		require.Len(t, sizes, 1)
+		assert.Greater(t, sizes[0], 200)
+	})
+}
@@ -0,0 +1,31 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// marshalProto returns a protobuf message as the object that `protojson` writes for it (so that enums are
+// written by name and oneofs are written as their set field); any other value is returned as-is.
+func marshalProto(value interface{}) (interface{}, error) {
+	message, okay := value.(proto.Message)
+	if !okay {
+		return value, nil
+	}
+	contents, err := protojson.Marshal(message)
+	if err != nil {
+		return nil, err
+	}
+
+	// Decode the JSON so that the redaction and other options apply to the message's fields.
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.UseNumber()
+	var output interface{}
+	if err := decoder.Decode(&output); err != nil {
+		return nil, err
+	}
+	return output, nil
+}
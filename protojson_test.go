@@ -0,0 +1,78 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithProtoJSON(t *testing.T) {
+	logger := logrus.New()
+	message := &loggingpb.LogEntry{
+		LogName:  "projects/project/logs/test",
+		Severity: 500, // ERROR
+		Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: "payload"},
+		SourceLocation: &loggingpb.LogEntrySourceLocation{
+			File:     "main.go",
+			Line:     10,
+			Function: "main",
+		},
+	}
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Message",
+			options:     []Option{WithProtoJSON(true)},
+			fields:      logrus.Fields{"entry": message},
+			output:      []byte(`{"entry":{"logName":"projects/project/logs/test","severity":"ERROR","sourceLocation":{"file":"main.go","function":"main","line":"10"},"textPayload":"payload"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty Message",
+			options:     []Option{WithProtoJSON(true)},
+			fields:      logrus.Fields{"entry": &loggingpb.LogEntry{}},
+			output:      []byte(`{"entry":{},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Other Values",
+			options:     []Option{WithProtoJSON(true)},
+			fields:      logrus.Fields{"key": "value", "number": 1},
+			output:      []byte(`{"key":"value","message":"test","number":1,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Redaction",
+			options:     []Option{WithProtoJSON(true), WithRedactKeys("file")},
+			fields:      logrus.Fields{"location": message.SourceLocation},
+			output:      []byte(`{"location":{"file":"[REDACTED]","function":"main","line":"10"},"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		e := logger.WithField("entry", message)
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		result, err := New().Format(e)
+		require.Nil(t, err)
+		assert.NotContains(t, string(result), `"severity":"ERROR"`)
+	})
+}
@@ -0,0 +1,39 @@
+package gcfstructuredlogformatter
+
+import (
+	"strings"
+)
+
+// RedactedValue replaces the value of a redacted field.
+const RedactedValue = "[REDACTED]"
+
+// redactString returns the string with every match of the `RedactPatterns` replaced by `RedactedValue`.
+func (f *Formatter) redactString(value string) string {
+	for _, pattern := range f.RedactPatterns {
+		value = pattern.ReplaceAllLiteralString(value, RedactedValue)
+	}
+	return value
+}
+
+// redactField returns the string value of a logrus field that is written outside of the fields (such as a label),
+// with `RedactedValue` in place of the whole value if the key is redacted, or else with the `RedactPatterns` applied.
+func (f *Formatter) redactField(key, value string) string {
+	if f.isRedactedKey(key) {
+		return RedactedValue
+	}
+	return f.redactString(value)
+}
+
+// isRedactedKey returns true if the value of the key should be redacted.
+//
+// Keys are matched case-insensitively; if `RedactSubstrings` is set, then a key matches if it contains any of the `RedactKeys`.
+func (f *Formatter) isRedactedKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, redactKey := range f.RedactKeys {
+		redactKey = strings.ToLower(redactKey)
+		if key == redactKey || (f.RedactSubstrings && strings.Contains(key, redactKey)) {
+			return true
+		}
+	}
+	return false
+}
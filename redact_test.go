@@ -0,0 +1,209 @@
+package gcfstructuredlogformatter
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithRedactKeys(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		input       logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			input:       logrus.Fields{"password": "hunter2"},
+			output:      []byte(`{"message":"test","password":"hunter2","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Case Insensitive",
+			options:     []Option{WithRedactKeys("password")},
+			input:       logrus.Fields{"Password": "hunter2", "user": "alice"},
+			output:      []byte(`{"Password":"[REDACTED]","message":"test","severity":"Info","user":"alice"}` + "\n"),
+		},
+		{
+			description: "Exact Match Only",
+			options:     []Option{WithRedactKeys("token")},
+			input:       logrus.Fields{"token": "abc", "access_token": "def"},
+			output:      []byte(`{"access_token":"def","message":"test","severity":"Info","token":"[REDACTED]"}` + "\n"),
+		},
+		{
+			description: "Substring Match",
+			options:     []Option{WithRedactKeys("token"), WithRedactSubstrings(true)},
+			input:       logrus.Fields{"token": "abc", "Access_Token": "def", "user": "alice"},
+			output:      []byte(`{"Access_Token":"[REDACTED]","message":"test","severity":"Info","token":"[REDACTED]","user":"alice"}` + "\n"),
+		},
+		{
+			description: "Redacted Map",
+			options:     []Option{WithRedactKeys("credentials")},
+			input:       logrus.Fields{"credentials": map[string]interface{}{"user": "alice", "password": "hunter2"}},
+			output:      []byte(`{"credentials":"[REDACTED]","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Nested Maps",
+			options:     []Option{WithRedactKeys("password")},
+			input: logrus.Fields{
+				"request": map[string]interface{}{
+					"user":     "alice",
+					"password": "hunter2",
+					"nested": logrus.Fields{
+						"password": "hunter3",
+					},
+					"headers": map[string]string{
+						"Password": "hunter4",
+						"Accept":   "*/*",
+					},
+				},
+			},
+			output: []byte(`{"message":"test","request":{"headers":{"Accept":"*/*","Password":"[REDACTED]"},"nested":{"password":"[REDACTED]"},"password":"[REDACTED]","user":"alice"},"severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.input)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+
+	t.Run("Original Unchanged", func(t *testing.T) {
+		request := map[string]interface{}{"password": "hunter2"}
+		e := logger.WithField("request", request)
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithRedactKeys("password"))
+		_, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, "hunter2", request["password"])
+	})
+}
+
+func TestFormatWithValueRedaction(t *testing.T) {
+	logger := logrus.New()
+	creditCard := regexp.MustCompile(`\b\d{4}-\d{4}-\d{4}-\d{4}\b`)
+	rows := []struct {
+		description string
+		options     []Option
+		message     string
+		input       logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			message:     "card 1234-5678-9012-3456",
+			input:       logrus.Fields{"card": "1234-5678-9012-3456"},
+			output:      []byte(`{"card":"1234-5678-9012-3456","message":"card 1234-5678-9012-3456","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Message and Fields",
+			options:     []Option{WithValueRedaction(creditCard)},
+			message:     "card 1234-5678-9012-3456 declined",
+			input: logrus.Fields{
+				"note":   "paid with 1234-5678-9012-3456 and 9999-9999-9999-9999",
+				"count":  1234,
+				"nested": map[string]interface{}{"card": "1234-5678-9012-3456"},
+			},
+			output: []byte(`{"count":1234,"message":"card [REDACTED] declined","nested":{"card":"[REDACTED]"},"note":"paid with [REDACTED] and [REDACTED]","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Combined with Keys",
+			options:     []Option{WithValueRedaction(creditCard), WithRedactKeys("password")},
+			message:     "test",
+			input:       logrus.Fields{"password": "hunter2", "note": "1234-5678-9012-3456"},
+			output:      []byte(`{"message":"test","note":"[REDACTED]","password":"[REDACTED]","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Promoted Labels",
+			options:     []Option{WithValueRedaction(creditCard), WithRedactKeys("password"), WithLabelKeys("password", "note", "tenant")},
+			message:     "test",
+			input:       logrus.Fields{"password": "hunter2", "note": "card 1234-5678-9012-3456", "tenant": "acme"},
+			output:      []byte(`{"logging.googleapis.com/labels":{"note":"card [REDACTED]","password":"[REDACTED]","tenant":"acme"},"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Component Key",
+			options:     []Option{WithRedactKeys("token"), WithComponentField("token")},
+			message:     "test",
+			input:       logrus.Fields{"token": "secret"},
+			output:      []byte(`{"component":"[REDACTED]","message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Component Value",
+			options:     []Option{WithValueRedaction(creditCard), WithComponentField("component")},
+			message:     "test",
+			input:       logrus.Fields{"component": "billing 1234-5678-9012-3456"},
+			output:      []byte(`{"component":"billing [REDACTED]","message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.input)
+			e.Message = row.message
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func BenchmarkFormatWithValueRedaction(b *testing.B) {
+	rows := []struct {
+		description string
+		options     []Option
+	}{
+		{
+			description: "Disabled",
+		},
+		{
+			description: "One Pattern",
+			options: []Option{
+				WithValueRedaction(regexp.MustCompile(`\b\d{4}-\d{4}-\d{4}-\d{4}\b`)),
+			},
+		},
+		{
+			description: "Three Patterns",
+			options: []Option{
+				WithValueRedaction(
+					regexp.MustCompile(`\b\d{4}-\d{4}-\d{4}-\d{4}\b`),
+					regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+					regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+				),
+			},
+		},
+	}
+
+	logger := logrus.New()
+	e := logger.WithFields(logrus.Fields{"prop": "value", "note": "paid with 1234-5678-9012-3456", "count": 42})
+	e.Message = "payment from alice@example.com declined"
+	e.Level = logrus.InfoLevel
+
+	for _, row := range rows {
+		b.Run(row.description, func(b *testing.B) {
+			formatter := New(row.options...)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := formatter.Format(e)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package gcfstructuredlogformatter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ReservedKeyPolicy determines what happens when a logrus field has the same key as a field
+// that the formatter has already written (such as the severity or message).
+type ReservedKeyPolicy int
+
+const (
+	// ReservedKeyRename renames the logrus field to "fields.KEY", like logrus's own JSON formatter does.
+	// This is the default.
+	ReservedKeyRename ReservedKeyPolicy = iota
+	// ReservedKeyDrop drops the logrus field.
+	ReservedKeyDrop
+	// ReservedKeyOverwrite overwrites the formatter's field with the logrus field.
+	ReservedKeyOverwrite
+	// ReservedKeyError makes `Format` return an error (see `ErrReservedKey`) for a logrus field with the same key as
+	// any of the formatter's own keys, even one that it did not write for the entry (such as an omitted timestamp).
+	// This is meant for tests, so that a collision fails fast.
+	ReservedKeyError
+)
+
+// ErrReservedKey is returned (wrapped) when a logrus field collides with a reserved key (see `ReservedKeyError`).
+var ErrReservedKey = errors.New("field collides with a reserved key")
+
+// ReservedKeyPrefix is the prefix given to logrus fields that are renamed by `ReservedKeyRename`.
+const ReservedKeyPrefix = "fields."
+
+// addField adds a logrus field to the map entry, taking the reserved key policy into account.
+//
+// With `LabelsFlat`, every key with the `FlatLabelPrefix` belongs to the labels, so a logrus field with such a key
+// always collides (even if there is no label with that key).
+func (f *Formatter) addField(mapEntry map[string]interface{}, key string, value interface{}) error {
+	_, collides := mapEntry[key]
+	if !collides && f.LabelsMode == LabelsFlat && strings.HasPrefix(key, FlatLabelPrefix) {
+		collides = true
+	}
+	if !collides && f.ReservedKeyPolicy == ReservedKeyError {
+		collides = containsKey(f.reservedKeys(mapEntry), key)
+	}
+	if collides {
+		switch f.ReservedKeyPolicy {
+		case ReservedKeyDrop:
+			return nil
+		case ReservedKeyOverwrite:
+		case ReservedKeyError:
+			return fmt.Errorf("%w: %q", ErrReservedKey, key)
+		default:
+			key = ReservedKeyPrefix + key
+		}
+	}
+	mapEntry[key] = value
+	return nil
+}
@@ -0,0 +1,119 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"encoding/json"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithReservedKeys(t *testing.T) {
+	reservedKeys := []string{
+		SeverityKey,
+		MessageKey,
+		TimestampKey,
+		InsertIDKey,
+		TraceKey,
+		TraceSampledKey,
+		SpanKey,
+		SourceLocationKey,
+		OperationKey,
+		HTTPRequestKey,
+		LabelsKey,
+	}
+
+	newEntry := func(fields logrus.Fields) *logrus.Entry {
+		ctx := context.Background()
+		ctx = context.WithValue(ctx, ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001"})
+		ctx = context.WithValue(ctx, ContextKeyOperation, Operation{ID: "my-operation"})
+		ctx = context.WithValue(ctx, ContextKeyHTTPRequest, HTTPRequest{RequestMethod: "GET"})
+		e := logrus.New().WithContext(ctx).WithFields(fields)
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		e.Time = time.Date(2024, time.June, 15, 8, 30, 15, 0, time.UTC)
+		e.Caller = &runtime.Frame{File: "file.go", Line: 1, Function: "function"}
+		return e
+	}
+	format := func(t *testing.T, policy ReservedKeyPolicy, entry *logrus.Entry) map[string]interface{} {
+		formatter := New(
+			WithReservedKeyPolicy(policy),
+			WithLabels(map[string]string{"key": "value"}),
+			WithReportCaller(true),
+			WithInsertIDFunc(func(*logrus.Entry) string { return "my-insert-id" }),
+		)
+		result, err := formatter.Format(entry)
+		require.Nil(t, err)
+
+		output := map[string]interface{}{}
+		err = json.Unmarshal(result, &output)
+		require.Nil(t, err)
+		return output
+	}
+
+	baseline := format(t, ReservedKeyRename, newEntry(nil))
+	for _, key := range reservedKeys {
+		require.Contains(t, baseline, key)
+	}
+
+	for _, key := range reservedKeys {
+		t.Run(key, func(t *testing.T) {
+			t.Run("Rename", func(t *testing.T) {
+				output := format(t, ReservedKeyRename, newEntry(logrus.Fields{key: "bogus"}))
+				assert.Equal(t, baseline[key], output[key])
+				assert.Equal(t, "bogus", output[ReservedKeyPrefix+key])
+			})
+			t.Run("Drop", func(t *testing.T) {
+				output := format(t, ReservedKeyDrop, newEntry(logrus.Fields{key: "bogus"}))
+				assert.Equal(t, baseline, output)
+			})
+			t.Run("Overwrite", func(t *testing.T) {
+				output := format(t, ReservedKeyOverwrite, newEntry(logrus.Fields{key: "bogus"}))
+				assert.Equal(t, "bogus", output[key])
+				assert.NotContains(t, output, ReservedKeyPrefix+key)
+			})
+			t.Run("Error", func(t *testing.T) {
+				formatter := New(WithReservedKeyPolicy(ReservedKeyError), WithLabels(map[string]string{"key": "value"}))
+				_, err := formatter.Format(newEntry(logrus.Fields{key: "bogus"}))
+				require.NotNil(t, err)
+				assert.ErrorIs(t, err, ErrReservedKey)
+				assert.Contains(t, err.Error(), key)
+			})
+		})
+	}
+
+	t.Run("No Collision", func(t *testing.T) {
+		output := format(t, ReservedKeyRename, newEntry(logrus.Fields{"prop": "value"}))
+		assert.Equal(t, "value", output["prop"])
+
+		output = format(t, ReservedKeyError, newEntry(logrus.Fields{"prop": "value"}))
+		assert.Equal(t, "value", output["prop"])
+	})
+
+	t.Run("Error on Unwritten Key", func(t *testing.T) {
+		e := logrus.NewEntry(logrus.New()).WithField(TimestampKey, "bogus")
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithReservedKeyPolicy(ReservedKeyError), WithDisableTimestamp(true))
+		_, err := formatter.Format(e)
+		assert.ErrorIs(t, err, ErrReservedKey)
+
+		formatter = New(WithDisableTimestamp(true))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"","severity":"Info","time":"bogus"}`+"\n", string(result))
+	})
+
+	t.Run("Error on Data Key", func(t *testing.T) {
+		e := logrus.NewEntry(logrus.New()).WithField("prop", "value")
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithReservedKeyPolicy(ReservedKeyError), WithDataKey(MessageKey))
+		_, err := formatter.Format(e)
+		assert.ErrorIs(t, err, ErrReservedKey)
+	})
+}
@@ -0,0 +1,35 @@
+package gcfstructuredlogformatter
+
+// Resource is the monitored resource that produced a log entry.
+//
+// See: https://cloud.google.com/logging/docs/reference/v2/rest/v2/MonitoredResource
+type Resource struct {
+	Type   string            `json:"type,omitempty"`   // The monitored resource type; for example, "gce_instance" or "global".
+	Labels map[string]string `json:"labels,omitempty"` // The labels that identify the resource; for example, "instance_id".
+}
+
+// isEmpty returns true if the resource has neither a type nor any labels.
+func (r *Resource) isEmpty() bool {
+	return r == nil || (r.Type == "" && len(r.Labels) == 0)
+}
+
+// resource returns the monitored resource, with the `ResourceLabels` added to its labels.
+func (f *Formatter) resource() *Resource {
+	if len(f.ResourceLabels) == 0 {
+		return f.Resource
+	}
+
+	resource := &Resource{
+		Labels: make(map[string]string, len(f.ResourceLabels)),
+	}
+	if f.Resource != nil {
+		resource.Type = f.Resource.Type
+		for key, value := range f.Resource.Labels {
+			resource.Labels[key] = value
+		}
+	}
+	for key, value := range f.ResourceLabels {
+		resource.Labels[key] = value
+	}
+	return resource
+}
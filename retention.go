@@ -0,0 +1,32 @@
+package gcfstructuredlogformatter
+
+const (
+	// RetentionKey is the default key for the retention hint (see `Formatter.RetentionKey`).
+	RetentionKey = "retention_days"
+	// RetentionField is the logrus field for the retention hint of a single entry.
+	//
+	// Its value is written as-is to the retention key instead of with the other fields; it takes precedence over
+	// `Formatter.DefaultRetentionDays`.
+	RetentionField = "_retention"
+)
+
+// retentionKey returns the key for the retention hint.
+func (f *Formatter) retentionKey() string {
+	if f.RetentionKey != "" {
+		return f.RetentionKey
+	}
+	return RetentionKey
+}
+
+// retention returns the retention hint for an entry with the given logrus fields.
+//
+// If there is no hint, then this returns false.
+func (f *Formatter) retention(fields map[string]interface{}) (interface{}, bool) {
+	if value, okay := fields[RetentionField]; okay {
+		return value, true
+	}
+	if f.DefaultRetentionDays > 0 {
+		return f.DefaultRetentionDays, true
+	}
+	return nil, false
+}
@@ -0,0 +1,77 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithRetention(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		fields      logrus.Fields
+		output      []byte
+	}{
+		{
+			description: "None",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Entry",
+			fields:      logrus.Fields{RetentionField: 3},
+			output:      []byte(`{"message":"test","retention_days":3,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Default",
+			options:     []Option{WithDefaultRetentionDays(30)},
+			output:      []byte(`{"message":"test","retention_days":30,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Entry over Default",
+			options:     []Option{WithDefaultRetentionDays(30)},
+			fields:      logrus.Fields{RetentionField: 3},
+			output:      []byte(`{"message":"test","retention_days":3,"severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Passthrough",
+			options:     []Option{WithDefaultRetentionDays(30)},
+			fields:      logrus.Fields{RetentionField: "short"},
+			output:      []byte(`{"message":"test","retention_days":"short","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Custom Key",
+			options:     []Option{WithRetentionKey("ttl"), WithDefaultRetentionDays(30)},
+			fields:      logrus.Fields{RetentionField: 3},
+			output:      []byte(`{"message":"test","severity":"Info","ttl":3}` + "\n"),
+		},
+		{
+			description: "Ordered Keys",
+			options:     []Option{WithRetentionKey("ttl"), WithOrderedKeys(true)},
+			fields:      logrus.Fields{RetentionField: 3, "key": "value"},
+			output:      []byte(`{"severity":"Info","message":"test","ttl":3,"key":"value"}` + "\n"),
+		},
+		{
+			description: "Collision",
+			options:     []Option{WithDefaultRetentionDays(30)},
+			fields:      logrus.Fields{RetentionKey: 3},
+			output:      []byte(`{"fields.retention_days":3,"message":"test","retention_days":30,"severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(row.fields)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
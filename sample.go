@@ -0,0 +1,35 @@
+package gcfstructuredlogformatter
+
+import (
+	"math/rand"
+
+	"github.com/sirupsen/logrus"
+)
+
+// isSampledOut returns true if the entry is dropped by the `SampleRates`.
+//
+// Entries at `logrus.ErrorLevel` or more severe are never sampled out.
+func (f *Formatter) isSampledOut(level logrus.Level) bool {
+	if level <= logrus.ErrorLevel {
+		return false
+	}
+	rate, okay := f.SampleRates[level]
+	if !okay || rate >= 1 {
+		return false
+	}
+	if rate <= 0 {
+		return true
+	}
+	return f.sampleFloat64() >= rate
+}
+
+// sampleFloat64 returns a random number in [0, 1) for sampling.
+func (f *Formatter) sampleFloat64() float64 {
+	f.sampleMutex.Lock()
+	defer f.sampleMutex.Unlock()
+
+	if f.sampleRand == nil {
+		return rand.Float64()
+	}
+	return f.sampleRand.Float64()
+}
@@ -0,0 +1,98 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithSampleRates(t *testing.T) {
+	const count = 10000
+
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		level       logrus.Level
+		minimum     int
+		maximum     int
+	}{
+		{
+			description: "Sampled",
+			level:       logrus.DebugLevel,
+			minimum:     count * 8 / 100,
+			maximum:     count * 12 / 100,
+		},
+		{
+			description: "Never Kept",
+			level:       logrus.TraceLevel,
+			minimum:     0,
+			maximum:     0,
+		},
+		{
+			description: "Not Sampled",
+			level:       logrus.InfoLevel,
+			minimum:     count,
+			maximum:     count,
+		},
+		{
+			description: "Error Is Never Sampled",
+			level:       logrus.ErrorLevel,
+			minimum:     count,
+			maximum:     count,
+		},
+		{
+			description: "Fatal Is Never Sampled",
+			level:       logrus.FatalLevel,
+			minimum:     count,
+			maximum:     count,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(
+				WithSampleSeed(1),
+				WithSampleRates(map[logrus.Level]float64{
+					logrus.TraceLevel: 0,
+					logrus.DebugLevel: 0.1,
+					logrus.ErrorLevel: 0,
+					logrus.FatalLevel: 0.5,
+				}),
+			)
+
+			kept := 0
+			for i := 0; i < count; i++ {
+				e := logrus.NewEntry(logger)
+				e.Message = "test"
+				e.Level = row.level
+				result, err := formatter.Format(e)
+				require.Nil(t, err)
+				if len(result) > 0 {
+					kept++
+				}
+			}
+			assert.GreaterOrEqual(t, kept, row.minimum)
+			assert.LessOrEqual(t, kept, row.maximum)
+		})
+	}
+}
+
+func TestFormatWithSampleSeed(t *testing.T) {
+	logger := logrus.New()
+	run := func() []bool {
+		formatter := New(WithSampleSeed(42), WithSampleRates(map[logrus.Level]float64{logrus.DebugLevel: 0.5}))
+		var kept []bool
+		for i := 0; i < 100; i++ {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = logrus.DebugLevel
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			kept = append(kept, len(result) > 0)
+		}
+		return kept
+	}
+	assert.Equal(t, run(), run())
+}
@@ -0,0 +1,66 @@
+package gcfstructuredlogformatter
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// isSanitizing returns true if the logrus fields need to be sanitized.
+func (f *Formatter) isSanitizing() bool {
+	return len(f.RedactKeys) > 0 || len(f.RedactPatterns) > 0 || f.MaxFieldBytes > 0 || f.DurationFormat != DurationNanoseconds || f.TimeLayout != ""
+}
+
+// sanitizeString returns the string with any redactions and truncation applied.
+func (f *Formatter) sanitizeString(value string) string {
+	return truncateString(f.redactString(value), f.MaxFieldBytes)
+}
+
+// sanitize returns the value with any redacted keys replaced by `RedactedValue`, any strings
+// redacted and truncated, any durations written in the `DurationFormat`, and any times written with the `TimeLayout`.
+//
+// Nested maps are walked so that redacted keys and strings at any depth are sanitized.
+// The original value is never modified.
+func (f *Formatter) sanitize(key string, value interface{}) interface{} {
+	if f.isRedactedKey(key) {
+		return RedactedValue
+	}
+	switch value := value.(type) {
+	case string:
+		return f.sanitizeString(value)
+	case time.Duration:
+		return f.formatDuration(value)
+	case *time.Duration:
+		if value != nil {
+			return f.formatDuration(*value)
+		}
+	case time.Time:
+		return f.formatTime(value)
+	case *time.Time:
+		if value != nil {
+			return f.formatTime(*value)
+		}
+	case map[string]interface{}:
+		output := make(map[string]interface{}, len(value))
+		for k, v := range value {
+			output[k] = f.sanitize(k, v)
+		}
+		return output
+	case logrus.Fields:
+		output := make(logrus.Fields, len(value))
+		for k, v := range value {
+			output[k] = f.sanitize(k, v)
+		}
+		return output
+	case map[string]string:
+		output := make(map[string]string, len(value))
+		for k, v := range value {
+			if f.isRedactedKey(k) {
+				v = RedactedValue
+			}
+			output[k] = f.sanitizeString(v)
+		}
+		return output
+	}
+	return value
+}
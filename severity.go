@@ -0,0 +1,71 @@
+package gcfstructuredlogformatter
+
+import (
+	"strings"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+)
+
+// SeverityField is the logrus field for a severity that overrides the one mapped from the logrus level
+// (for example, `WithField(SeverityField, "Notice")`).
+//
+// The value must be a `logging.Severity` or its name (such as "Notice"); it takes precedence over `ContextKeySeverity`.
+// A valid value is not written with the other fields; an invalid value is ignored (and written as a regular field).
+const SeverityField = "gcp_severity"
+
+// SeverityStringer converts a Google severity into the string that is written (see `Formatter.SeverityStringer`).
+type SeverityStringer func(severity logging.Severity) string
+
+// UppercaseSeverity is a `SeverityStringer` that writes the severity in uppercase (such as "WARNING"),
+// as the Cloud Logging API's `LogSeverity` enum names it.
+func UppercaseSeverity(severity logging.Severity) string {
+	return strings.ToUpper(severity.String())
+}
+
+// severityString returns the string that is written for the severity.
+func (f *Formatter) severityString(severity logging.Severity) string {
+	if f.SeverityStringer != nil {
+		return f.SeverityStringer(severity)
+	}
+	return severity.String()
+}
+
+// GoogleSeverity returns the Google severity (such as "Warning") that the default mapping writes for a logrus level.
+//
+// A level that is not in the default mapping (such as a custom level) returns "Default".
+func GoogleSeverity(level logrus.Level) string {
+	return logrusToGoogleSeverityMap[level].String()
+}
+
+// LevelForSeverity returns the logrus level that the default mapping maps to the given Google severity (such as "Warning").
+//
+// The severity name is case-insensitive.
+// If no logrus level maps to the severity, then this returns false.
+func LevelForSeverity(severity string) (logrus.Level, bool) {
+	for level, s := range logrusToGoogleSeverityMap {
+		if strings.EqualFold(s.String(), severity) {
+			return level, true
+		}
+	}
+	return logrus.PanicLevel, false
+}
+
+// parseSeverity returns the severity that the value holds; the value may be a `logging.Severity` or its name.
+//
+// If the value is not a valid severity, then this returns false.
+func parseSeverity(value interface{}) (logging.Severity, bool) {
+	switch value := value.(type) {
+	case logging.Severity:
+		// An unknown severity has a numeric name, which does not parse back to itself.
+		if logging.ParseSeverity(value.String()) == value {
+			return value, true
+		}
+	case string:
+		severity := logging.ParseSeverity(value)
+		if severity != logging.Default || strings.EqualFold(value, logging.Default.String()) {
+			return severity, true
+		}
+	}
+	return logging.Default, false
+}
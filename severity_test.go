@@ -0,0 +1,209 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoogleSeverity(t *testing.T) {
+	rows := []struct {
+		level    logrus.Level
+		severity string
+	}{
+		{level: logrus.PanicLevel, severity: "Emergency"},
+		{level: logrus.FatalLevel, severity: "Alert"},
+		{level: logrus.ErrorLevel, severity: "Error"},
+		{level: logrus.WarnLevel, severity: "Warning"},
+		{level: logrus.InfoLevel, severity: "Info"},
+		{level: logrus.DebugLevel, severity: "Debug"},
+		{level: logrus.TraceLevel, severity: "Default"},
+	}
+
+	for _, row := range rows {
+		t.Run(row.level.String(), func(t *testing.T) {
+			assert.Equal(t, row.severity, GoogleSeverity(row.level))
+
+			level, okay := LevelForSeverity(row.severity)
+			require.True(t, okay)
+			assert.Equal(t, row.level, level)
+
+			// This matches what the formatter writes.
+			e := logrus.NewEntry(logrus.New())
+			e.Level = row.level
+			result, err := New().Format(e)
+			require.Nil(t, err)
+			assert.Contains(t, string(result), `"severity":"`+row.severity+`"`)
+		})
+	}
+
+	t.Run("Custom Level", func(t *testing.T) {
+		assert.Equal(t, "Default", GoogleSeverity(logrus.Level(42)))
+	})
+
+	t.Run("Case Insensitive", func(t *testing.T) {
+		level, okay := LevelForSeverity("warning")
+		require.True(t, okay)
+		assert.Equal(t, logrus.WarnLevel, level)
+	})
+
+	t.Run("Unmapped Severity", func(t *testing.T) {
+		_, okay := LevelForSeverity("Critical")
+		assert.False(t, okay)
+		_, okay = LevelForSeverity("loud")
+		assert.False(t, okay)
+	})
+}
+
+func TestFormatWithSeverityStringer(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		level       logrus.Level
+		output      []byte
+	}{
+		{
+			description: "Default",
+			level:       logrus.WarnLevel,
+			output:      []byte(`{"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Uppercase Warning",
+			options:     []Option{WithSeverityStringer(UppercaseSeverity)},
+			level:       logrus.WarnLevel,
+			output:      []byte(`{"message":"test","severity":"WARNING"}` + "\n"),
+		},
+		{
+			description: "Uppercase Error",
+			options:     []Option{WithSeverityStringer(UppercaseSeverity)},
+			level:       logrus.ErrorLevel,
+			output:      []byte(`{"message":"test","severity":"ERROR"}` + "\n"),
+		},
+		{
+			description: "Uppercase Default",
+			options:     []Option{WithSeverityStringer(UppercaseSeverity)},
+			level:       logrus.TraceLevel,
+			output:      []byte(`{"message":"test","severity":"DEFAULT"}` + "\n"),
+		},
+		{
+			description: "Custom",
+			options: []Option{WithSeverityStringer(func(severity logging.Severity) string {
+				return strconv.Itoa(int(severity))
+			})},
+			level:  logrus.InfoLevel,
+			output: []byte(`{"message":"test","severity":"200"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logrus.NewEntry(logger)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithSeverityField(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description     string
+		options         []Option
+		level           logrus.Level
+		fields          logrus.Fields
+		contextSeverity interface{}
+		output          []byte
+	}{
+		{
+			description: "None",
+			level:       logrus.InfoLevel,
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Name over Level",
+			level:       logrus.InfoLevel,
+			fields:      logrus.Fields{SeverityField: "Notice"},
+			output:      []byte(`{"message":"test","severity":"Notice"}` + "\n"),
+		},
+		{
+			description: "Severity over Level",
+			level:       logrus.InfoLevel,
+			fields:      logrus.Fields{SeverityField: logging.Critical},
+			output:      []byte(`{"message":"test","severity":"Critical"}` + "\n"),
+		},
+		{
+			description: "Lower than Level",
+			level:       logrus.ErrorLevel,
+			fields:      logrus.Fields{SeverityField: "debug"},
+			output:      []byte(`{"message":"test","severity":"Debug"}` + "\n"),
+		},
+		{
+			description:     "Field over Context",
+			level:           logrus.InfoLevel,
+			fields:          logrus.Fields{SeverityField: "Notice"},
+			contextSeverity: logging.Alert,
+			output:          []byte(`{"message":"test","severity":"Notice"}` + "\n"),
+		},
+		{
+			description:     "Invalid Field",
+			level:           logrus.InfoLevel,
+			fields:          logrus.Fields{SeverityField: "loud"},
+			contextSeverity: logging.Alert,
+			output:          []byte(`{"gcp_severity":"loud","message":"test","severity":"Alert"}` + "\n"),
+		},
+		{
+			description: "Invalid Field Type",
+			level:       logrus.WarnLevel,
+			fields:      logrus.Fields{SeverityField: 500},
+			output:      []byte(`{"gcp_severity":500,"message":"test","severity":"Warning"}` + "\n"),
+		},
+		{
+			description: "Max Severity",
+			options:     []Option{WithMaxSeverity(logging.Error)},
+			level:       logrus.InfoLevel,
+			fields:      logrus.Fields{SeverityField: "Emergency"},
+			output:      []byte(`{"message":"test","severity":"Error"}` + "\n"),
+		},
+		{
+			description: "Severity Map",
+			options:     []Option{WithSeverityMap(map[logrus.Level]logging.Severity{logrus.InfoLevel: logging.Debug})},
+			level:       logrus.InfoLevel,
+			fields:      logrus.Fields{SeverityField: "Info"},
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Other Fields",
+			level:       logrus.InfoLevel,
+			fields:      logrus.Fields{SeverityField: "Notice", "key": "value"},
+			output:      []byte(`{"key":"value","message":"test","severity":"Notice"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.Background()
+			if row.contextSeverity != nil {
+				ctx = context.WithValue(ctx, ContextKeySeverity, row.contextSeverity)
+			}
+			e := logger.WithContext(ctx).WithFields(row.fields)
+			e.Message = "test"
+			e.Level = row.level
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SeverityWriter is a writer that writes each line of plain text as a log entry at a fixed level.
+//
+// This is useful for code that does not use logrus, such as a library that logs with the standard `log` package:
+//
+//	log.New(gcfstructuredlogformatter.NewSeverityWriter(os.Stderr, formatter, logrus.WarnLevel), "", 0)
+//
+// A partial line is held until the rest of it is written (or until `Flush` is called).
+type SeverityWriter struct {
+	writer    io.Writer
+	formatter *Formatter
+	level     logrus.Level
+	ctx       context.Context // If set, then this is the context for each entry (for the trace, labels, and so on).
+	mutex     sync.Mutex
+	pending   []byte // This is the partial line that has not been written yet.
+}
+
+// NewSeverityWriter creates a new severity writer that writes entries at the given level to the given writer.
+func NewSeverityWriter(writer io.Writer, formatter *Formatter, level logrus.Level) *SeverityWriter {
+	return &SeverityWriter{
+		writer:    writer,
+		formatter: formatter,
+		level:     level,
+	}
+}
+
+// WithContext returns a copy of the severity writer that uses the given context for each entry.
+//
+// The copy has its own partial line.
+func (w *SeverityWriter) WithContext(ctx context.Context) *SeverityWriter {
+	return &SeverityWriter{
+		writer:    w.writer,
+		formatter: w.formatter,
+		level:     w.level,
+		ctx:       ctx,
+	}
+}
+
+// Write writes each complete line as a log entry; empty lines are skipped.
+//
+// If a line cannot be written, then this returns the number of bytes of `p` before that line, and neither that line
+// nor the rest of `p` is kept (so that the caller may write them again).
+func (w *SeverityWriter) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	carried := len(w.pending) // This is the start of the partial line from an earlier write.
+	w.pending = append(w.pending, p...)
+	written := 0
+	for {
+		index := bytes.IndexByte(w.pending[written:], '\n')
+		if index < 0 {
+			break
+		}
+		if err := w.writeLine(w.pending[written : written+index]); err != nil {
+			w.pending = nil
+			return max(written-carried, 0), err
+		}
+		written += index + 1
+	}
+	w.pending = w.pending[written:]
+	if len(w.pending) == 0 {
+		// Let go of the buffer so that one long line does not pin memory.
+		w.pending = nil
+	}
+	return len(p), nil
+}
+
+// Flush writes the partial line, if there is one, as a log entry.
+func (w *SeverityWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	line := w.pending
+	w.pending = nil
+	return w.writeLine(line)
+}
+
+// writeLine writes a single line as a log entry; the mutex must be held.
+func (w *SeverityWriter) writeLine(line []byte) error {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return nil
+	}
+
+	entry := &logrus.Entry{
+		Data:    logrus.Fields{},
+		Time:    w.formatter.now(),
+		Level:   w.level,
+		Message: string(line),
+		Context: w.ctx,
+	}
+	contents, err := w.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		return nil
+	}
+	_, err = w.writer.Write(contents)
+	return err
+}
@@ -0,0 +1,126 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverityWriter(t *testing.T) {
+	t.Run("Lines", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewSeverityWriter(&buffer, New(WithDisableTimestamp(true)), logrus.WarnLevel)
+
+		n, err := writer.Write([]byte("first line\nsecond \"line\"\r\n\nthird"))
+		require.Nil(t, err)
+		assert.Equal(t, 32, n)
+		assert.Equal(t, `{"message":"first line","severity":"Warning"}`+"\n"+`{"message":"second \"line\"","severity":"Warning"}`+"\n", buffer.String())
+
+		buffer.Reset()
+		_, err = writer.Write([]byte(" line\n"))
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"third line","severity":"Warning"}`+"\n", buffer.String())
+	})
+
+	t.Run("Flush", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewSeverityWriter(&buffer, New(WithDisableTimestamp(true)), logrus.InfoLevel)
+
+		_, err := writer.Write([]byte("partial"))
+		require.Nil(t, err)
+		assert.Equal(t, "", buffer.String())
+
+		require.Nil(t, writer.Flush())
+		assert.Equal(t, `{"message":"partial","severity":"Info"}`+"\n", buffer.String())
+
+		buffer.Reset()
+		require.Nil(t, writer.Flush())
+		assert.Equal(t, "", buffer.String())
+	})
+
+	t.Run("Timestamp", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := New()
+		formatter.nowFunc = func() time.Time {
+			return time.Date(2020, 1, 2, 3, 4, 5, 6, time.FixedZone("EST", -5*60*60))
+		}
+		writer := NewSeverityWriter(&buffer, formatter, logrus.InfoLevel)
+
+		_, err := writer.Write([]byte("test\n"))
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"test","severity":"Info","time":"2020-01-02T08:04:05.000000006Z"}`+"\n", buffer.String())
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithLabels(map[string]string{"key": "value"}))
+		ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000"})
+		writer := NewSeverityWriter(&buffer, formatter, logrus.ErrorLevel).WithContext(ctx)
+
+		_, err := writer.Write([]byte("failure\n"))
+		require.Nil(t, err)
+		assert.Equal(t, `{"logging.googleapis.com/labels":{"key":"value"},"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"failure","severity":"Error"}`+"\n", buffer.String())
+	})
+
+	t.Run("Standard Logger", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewSeverityWriter(&buffer, New(WithDisableTimestamp(true)), logrus.DebugLevel)
+
+		logger := log.New(writer, "library: ", 0)
+		logger.Print("one")
+		logger.Print("two\nthree")
+		assert.Equal(t, `{"message":"library: one","severity":"Debug"}`+"\n"+`{"message":"library: two","severity":"Debug"}`+"\n"+`{"message":"three","severity":"Debug"}`+"\n", buffer.String())
+	})
+
+	t.Run("Dropped", func(t *testing.T) {
+		var buffer bytes.Buffer
+		writer := NewSeverityWriter(&buffer, New(WithMinLevel(logrus.InfoLevel)), logrus.DebugLevel)
+
+		_, err := writer.Write([]byte("noise\n"))
+		require.Nil(t, err)
+		assert.Equal(t, "", buffer.String())
+	})
+
+	t.Run("Failing Writer", func(t *testing.T) {
+		var buffer bytes.Buffer
+		target := &limitedWriter{writer: &buffer, remaining: 1}
+		writer := NewSeverityWriter(target, New(WithDisableTimestamp(true)), logrus.InfoLevel)
+
+		_, err := writer.Write([]byte("fir"))
+		require.Nil(t, err)
+		n, err := writer.Write([]byte("st\nsecond\nthird\n"))
+		assert.NotNil(t, err)
+		assert.Equal(t, 3, n)
+		assert.Equal(t, `{"message":"first","severity":"Info"}`+"\n", buffer.String())
+
+		// The rest is not kept, so writing it again does not write it twice.
+		buffer.Reset()
+		target.remaining = 2
+		n, err = writer.Write([]byte("second\nthird\n"))
+		require.Nil(t, err)
+		assert.Equal(t, 13, n)
+		assert.Equal(t, `{"message":"second","severity":"Info"}`+"\n"+`{"message":"third","severity":"Info"}`+"\n", buffer.String())
+	})
+}
+
+// limitedWriter is a writer that fails once it has written a given number of times.
+type limitedWriter struct {
+	writer    io.Writer
+	remaining int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.remaining <= 0 {
+		return 0, errors.New("writer is full")
+	}
+	w.remaining--
+	return w.writer.Write(p)
+}
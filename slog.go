@@ -0,0 +1,172 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// slogToLogrusLevel maps a slog level to the closest logrus level.
+//
+// This allows the slog handler to use the same severity mapping as the logrus formatter.
+func slogToLogrusLevel(level slog.Level) logrus.Level {
+	switch {
+	case level >= slog.LevelError:
+		return logrus.ErrorLevel
+	case level >= slog.LevelWarn:
+		return logrus.WarnLevel
+	case level >= slog.LevelInfo:
+		return logrus.InfoLevel
+	case level >= slog.LevelDebug:
+		return logrus.DebugLevel
+	default:
+		return logrus.TraceLevel
+	}
+}
+
+// Handler is a slog handler that writes entries in the same format as the logrus formatter.
+//
+// Source locations are controlled by the formatter's `ReportCaller` field.
+type Handler struct {
+	formatter *Formatter
+	writer    io.Writer
+	mutex     *sync.Mutex
+	level     slog.Leveler
+	fields    logrus.Fields // These are the fields from `WithAttrs`.
+	groups    []string      // These are the groups from `WithGroup`.
+}
+
+var _ slog.Handler = (*Handler)(nil)
+
+// NewHandler creates a new slog handler that writes to the given writer using the given formatter.
+//
+// If the formatter is nil, then a default formatter is used.
+// Only the `Level` of the handler options is used.
+func NewHandler(writer io.Writer, formatter *Formatter, options *slog.HandlerOptions) *Handler {
+	if formatter == nil {
+		formatter = New()
+	}
+	var level slog.Leveler = slog.LevelInfo
+	if options != nil && options.Level != nil {
+		level = options.Level
+	}
+	return &Handler{
+		formatter: formatter,
+		writer:    writer,
+		mutex:     &sync.Mutex{},
+		level:     level,
+		fields:    logrus.Fields{},
+	}
+}
+
+// Enabled returns true if the level is enabled.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle formats and writes the record.
+func (h *Handler) Handle(ctx context.Context, record slog.Record) error {
+	fields := copyFields(h.fields)
+	record.Attrs(func(attr slog.Attr) bool {
+		addAttr(fields, h.groups, attr)
+		return true
+	})
+
+	entry := &logrus.Entry{
+		Data:    fields,
+		Time:    record.Time,
+		Level:   slogToLogrusLevel(record.Level),
+		Message: record.Message,
+		Context: ctx,
+	}
+	if record.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{record.PC}).Next()
+		entry.Caller = &frame
+	}
+
+	contents, err := h.formatter.Format(entry)
+	if err != nil {
+		return err
+	}
+	if len(contents) == 0 {
+		// The formatter dropped the entry.
+		return nil
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err = h.writer.Write(contents)
+	return err
+}
+
+// WithAttrs returns a new handler with the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.fields = copyFields(h.fields)
+	for _, attr := range attrs {
+		addAttr(handler.fields, h.groups, attr)
+	}
+	return &handler
+}
+
+// WithGroup returns a new handler with the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handler := *h
+	handler.groups = append(append([]string{}, h.groups...), name)
+	return &handler
+}
+
+// copyFields returns a deep copy of the fields, including any nested groups.
+func copyFields(fields logrus.Fields) logrus.Fields {
+	output := logrus.Fields{}
+	for key, value := range fields {
+		if group, okay := value.(logrus.Fields); okay {
+			value = copyFields(group)
+		}
+		output[key] = value
+	}
+	return output
+}
+
+// addAttr adds a slog attribute to the fields, nested under the given groups.
+func addAttr(fields logrus.Fields, groups []string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	for _, group := range groups {
+		child, okay := fields[group].(logrus.Fields)
+		if !okay {
+			child = logrus.Fields{}
+			fields[group] = child
+		}
+		fields = child
+	}
+
+	if attr.Value.Kind() == slog.KindGroup {
+		groupAttrs := attr.Value.Group()
+		if len(groupAttrs) == 0 {
+			return
+		}
+		if attr.Key == "" {
+			// An empty group key means that the attributes are inlined.
+			for _, groupAttr := range groupAttrs {
+				addAttr(fields, nil, groupAttr)
+			}
+			return
+		}
+		for _, groupAttr := range groupAttrs {
+			addAttr(fields, []string{attr.Key}, groupAttr)
+		}
+		return
+	}
+	fields[attr.Key] = attr.Value.Any()
+}
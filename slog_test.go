@@ -0,0 +1,130 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+		SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	rows := []struct {
+		description string
+		slog        func(logger *slog.Logger)
+		logrus      func(logger *logrus.Logger)
+	}{
+		{
+			description: "Info",
+			slog: func(logger *slog.Logger) {
+				logger.Info("test", "prop", "value", "count", 3)
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.WithFields(logrus.Fields{"prop": "value", "count": 3}).Info("test")
+			},
+		},
+		{
+			description: "Warning",
+			slog: func(logger *slog.Logger) {
+				logger.Warn("test")
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.Warn("test")
+			},
+		},
+		{
+			description: "Error with Trace",
+			slog: func(logger *slog.Logger) {
+				logger.ErrorContext(ctx, "test")
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.WithContext(ctx).Error("test")
+			},
+		},
+		{
+			description: "Debug",
+			slog: func(logger *slog.Logger) {
+				logger.Debug("test")
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.Debug("test")
+			},
+		},
+		{
+			description: "Groups",
+			slog: func(logger *slog.Logger) {
+				logger.With("prop", "value").WithGroup("request").With("id", "1234").Info("test", slog.Group("user", "name", "alice"))
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.WithFields(logrus.Fields{
+					"prop": "value",
+					"request": map[string]interface{}{
+						"id": "1234",
+						"user": map[string]interface{}{
+							"name": "alice",
+						},
+					},
+				}).Info("test")
+			},
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithLabels(map[string]string{"key": "value"}), WithDisableTimestamp(true))
+
+			var slogBuffer bytes.Buffer
+			slogLogger := slog.New(NewHandler(&slogBuffer, formatter, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			row.slog(slogLogger)
+
+			var logrusBuffer bytes.Buffer
+			logrusLogger := logrus.New()
+			logrusLogger.SetOutput(&logrusBuffer)
+			logrusLogger.SetFormatter(formatter)
+			logrusLogger.SetLevel(logrus.DebugLevel)
+			row.logrus(logrusLogger)
+
+			require.NotEmpty(t, logrusBuffer.String())
+			assert.Equal(t, logrusBuffer.String(), slogBuffer.String())
+		})
+	}
+}
+
+func TestHandlerTimestamp(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(NewHandler(&buffer, nil, nil))
+	logger.Info("test")
+
+	output := map[string]interface{}{}
+	err := json.Unmarshal(buffer.Bytes(), &output)
+	require.Nil(t, err)
+	assert.Contains(t, output, TimestampKey)
+}
+
+func TestHandlerEnabled(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(NewHandler(&buffer, nil, nil))
+	logger.Debug("test")
+	assert.Empty(t, buffer.String())
+}
+
+func TestSlogToLogrusLevel(t *testing.T) {
+	assert.Equal(t, logrus.TraceLevel, slogToLogrusLevel(slog.LevelDebug-1))
+	assert.Equal(t, logrus.DebugLevel, slogToLogrusLevel(slog.LevelDebug))
+	assert.Equal(t, logrus.InfoLevel, slogToLogrusLevel(slog.LevelInfo))
+	assert.Equal(t, logrus.InfoLevel, slogToLogrusLevel(slog.LevelInfo+1))
+	assert.Equal(t, logrus.WarnLevel, slogToLogrusLevel(slog.LevelWarn))
+	assert.Equal(t, logrus.ErrorLevel, slogToLogrusLevel(slog.LevelError))
+	assert.Equal(t, logrus.ErrorLevel, slogToLogrusLevel(slog.LevelError+4))
+}
@@ -0,0 +1,23 @@
+package gcfstructuredlogformatter
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// stackTrace returns the stack trace of the entry's error field, if any.
+//
+// An error has a stack trace if its "%+v" form contains more than its message; this is the
+// convention used by github.com/pkg/errors and similar packages.
+func stackTrace(entry *logrus.Entry) string {
+	err, okay := entry.Data[logrus.ErrorKey].(error)
+	if !okay || err == nil {
+		return ""
+	}
+	stack := fmt.Sprintf("%+v", err)
+	if stack == err.Error() {
+		return ""
+	}
+	return stack
+}
@@ -0,0 +1,53 @@
+package gcfstructuredlogformatter
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithStackTraces(t *testing.T) {
+	logger := logrus.New()
+	format := func(t *testing.T, formatter *Formatter, err error) map[string]interface{} {
+		e := logger.WithError(err)
+		e.Message = "test"
+		e.Level = logrus.ErrorLevel
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+
+		output := map[string]interface{}{}
+		err = json.Unmarshal(result, &output)
+		require.Nil(t, err)
+		return output
+	}
+
+	t.Run("Plain Error", func(t *testing.T) {
+		output := format(t, New(WithStackTraces(true)), errors.New("plain error"))
+		assert.NotContains(t, output, StackTraceKey)
+	})
+
+	t.Run("Wrapped Plain Error", func(t *testing.T) {
+		output := format(t, New(WithStackTraces(true)), fmt.Errorf("wrapped: %w", errors.New("plain error")))
+		assert.NotContains(t, output, StackTraceKey)
+	})
+
+	t.Run("pkg/errors Error", func(t *testing.T) {
+		err := pkgerrors.Wrap(errors.New("plain error"), "wrapped")
+		output := format(t, New(WithStackTraces(true)), err)
+		require.Contains(t, output, StackTraceKey)
+		assert.Equal(t, fmt.Sprintf("%+v", err), output[StackTraceKey])
+		assert.Contains(t, output[StackTraceKey], "plain error\nwrapped\n")
+		assert.Contains(t, output[StackTraceKey], "TestFormatWithStackTraces")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		output := format(t, New(), pkgerrors.New("error"))
+		assert.NotContains(t, output, StackTraceKey)
+	})
+}
@@ -0,0 +1,138 @@
+package gcfstructuredlogformatter
+
+import (
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TeeSink is one of the writers that a `Tee` writes to.
+type TeeSink struct {
+	Writer io.Writer         // This is where the entries are written.
+	Labels map[string]string // These labels are added to each entry written to this sink; they take precedence over the entry's own labels.
+}
+
+// Tee is a logrus hook that formats each entry once and writes it to several sinks, each with its own labels.
+//
+// Add it to a logger with `logrus.Logger.AddHook`; the logger's own output may be set to `io.Discard`.
+// The formatter may also be the logger's own formatter: the tee keeps its own record of recent entries (see `Formatter.DedupWindow`),
+// so the two do not suppress each other's entries.
+type Tee struct {
+	formatter    *Formatter
+	sinks        []TeeSink
+	mutex        sync.Mutex   // This guards the writes to the sinks.
+	deduplicator deduplicator // This keeps track of the entries that the tee has written recently.
+}
+
+// NewTee creates a new tee that formats entries with the given formatter and writes them to the given sinks.
+func NewTee(formatter *Formatter, sinks ...TeeSink) *Tee {
+	return &Tee{
+		formatter: formatter,
+		sinks:     sinks,
+	}
+}
+
+// Levels returns the levels that the tee writes; this is every level.
+func (t *Tee) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes the entry to each of the sinks.
+//
+// The entry is built once (see `Formatter.BuildEntry`); each sink gets its own copy with its labels added.
+// Each copy is written the same way that `Formatter.Format` writes an entry, so `MarshalFallback`, `OnOversized`,
+// and `OnFormat` apply to each sink separately.
+// With `Formatter.DedupWindow`, any summaries (see `WithDedup`) are written first, each as its own write.
+// A sink that fails does not stop the entry from being written to the others; all of the errors are returned together.
+func (t *Tee) Fire(entry *logrus.Entry) error {
+	if t.formatter.isDropped(entry) {
+		return nil
+	}
+	repeated, suppressed, summaries := t.formatter.dedup(&t.deduplicator, entry)
+	summaryEntries := t.formatter.summaryEntries(entry, summaries)
+	var mapEntry map[string]interface{}
+	var fallback []byte
+	if !suppressed {
+		var err error
+		mapEntry, err = t.formatter.buildEntry(entry, repeated)
+		if err != nil {
+			if !t.formatter.MarshalFallback {
+				return err
+			}
+			fallback = t.formatter.fallbackLine(entry, err)
+		}
+	}
+	if len(summaryEntries) == 0 && mapEntry == nil && fallback == nil {
+		return nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var errs []error
+	write := func(writer io.Writer, contents []byte, err error) {
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		if _, err := writer.Write(contents); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, sink := range t.sinks {
+		for _, summaryEntry := range summaryEntries {
+			contents, err := t.formatter.Marshal(t.formatter.withLabels(summaryEntry, sink.Labels))
+			write(sink.Writer, contents, err)
+		}
+		switch {
+		case fallback != nil:
+			write(sink.Writer, fallback, nil)
+		case mapEntry != nil:
+			contents, err := t.formatter.encodeEntry(entry, t.formatter.withLabels(mapEntry, sink.Labels), nil)
+			write(sink.Writer, contents, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withLabels returns a copy of the map entry with the given labels added (taking the `LabelsMode` into account).
+func (f *Formatter) withLabels(mapEntry map[string]interface{}, overlay map[string]string) map[string]interface{} {
+	output := make(map[string]interface{}, len(mapEntry)+1)
+	for key, value := range mapEntry {
+		output[key] = value
+	}
+	if len(overlay) == 0 {
+		return output
+	}
+
+	// The flat labels are already top-level keys, so only the overlay needs to be written.
+	var existing map[string]string
+	switch f.LabelsMode {
+	case LabelsPlain:
+		existing, _ = mapEntry[PlainLabelsKey].(map[string]string)
+	case LabelsFlat:
+	default:
+		existing, _ = mapEntry[LabelsKey].(map[string]string)
+	}
+	labels := make(map[string]string, len(existing)+len(overlay))
+	for key, value := range existing {
+		labels[key] = value
+	}
+	for key, value := range overlay {
+		if f.SanitizeLabels {
+			key = sanitizeLabelKey(key)
+			if key == "" {
+				continue
+			}
+		}
+		labels[key] = value
+	}
+	if len(labels) == 0 {
+		return output
+	}
+
+	f.writeLabels(output, labels)
+	return output
+}
@@ -0,0 +1,186 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingWriter is a writer that always fails.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("sink is down")
+}
+
+func TestTee(t *testing.T) {
+	t.Run("Labels", func(t *testing.T) {
+		var billing, ops, plain bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithLabels(map[string]string{"service": "api"}))
+		tee := NewTee(formatter,
+			TeeSink{Writer: &billing, Labels: map[string]string{"export": "billing", "service": "billing-api"}},
+			TeeSink{Writer: &ops, Labels: map[string]string{"export": "ops"}},
+			TeeSink{Writer: &plain},
+		)
+
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		logger.AddHook(tee)
+		logger.WithField("key", "value").Info("test")
+
+		assert.Equal(t, `{"key":"value","logging.googleapis.com/labels":{"export":"billing","service":"billing-api"},"message":"test","severity":"Info"}`+"\n", billing.String())
+		assert.Equal(t, `{"key":"value","logging.googleapis.com/labels":{"export":"ops","service":"api"},"message":"test","severity":"Info"}`+"\n", ops.String())
+		assert.Equal(t, `{"key":"value","logging.googleapis.com/labels":{"service":"api"},"message":"test","severity":"Info"}`+"\n", plain.String())
+	})
+
+	t.Run("Labels Mode", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithLabelsMode(LabelsBoth))
+		tee := NewTee(formatter, TeeSink{Writer: &buffer, Labels: map[string]string{"export": "ops"}})
+
+		e := logrus.NewEntry(logrus.New())
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, `{"labels":{"export":"ops"},"logging.googleapis.com/labels":{"export":"ops"},"message":"test","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("Flat Labels Mode", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"service": "api"}))
+		tee := NewTee(formatter, TeeSink{Writer: &buffer, Labels: map[string]string{"export": "ops", "service": "ops-api"}})
+
+		e := logrus.NewEntry(logrus.New())
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, `{"label.export":"ops","label.service":"ops-api","message":"test","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("Failing Sink", func(t *testing.T) {
+		var first, last bytes.Buffer
+		formatter := New(WithDisableTimestamp(true))
+		tee := NewTee(formatter,
+			TeeSink{Writer: &first},
+			TeeSink{Writer: failingWriter{}},
+			TeeSink{Writer: &last},
+		)
+
+		e := logrus.NewEntry(logrus.New())
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		err := tee.Fire(e)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "sink is down")
+		assert.Equal(t, `{"message":"test","severity":"Info"}`+"\n", first.String())
+		assert.Equal(t, `{"message":"test","severity":"Info"}`+"\n", last.String())
+	})
+
+	t.Run("Built Once", func(t *testing.T) {
+		var first, second bytes.Buffer
+		calls := 0
+		formatter := New(WithDisableTimestamp(true), WithInsertIDFunc(func(*logrus.Entry) string {
+			calls++
+			return "1"
+		}))
+		tee := NewTee(formatter, TeeSink{Writer: &first}, TeeSink{Writer: &second})
+
+		e := logrus.NewEntry(logrus.New())
+		e.Level = logrus.InfoLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, first.String(), second.String())
+	})
+
+	t.Run("Shared Formatter with Dedup", func(t *testing.T) {
+		var main, sink bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithDedup(time.Minute))
+		logger := logrus.New()
+		logger.SetFormatter(formatter)
+		logger.SetOutput(&main)
+		logger.AddHook(NewTee(formatter, TeeSink{Writer: &sink}))
+
+		logger.Info("test")
+		logger.Info("test")
+		assert.Equal(t, `{"message":"test","severity":"Info"}`+"\n", main.String())
+		assert.Equal(t, `{"message":"test","severity":"Info"}`+"\n", sink.String())
+	})
+
+	t.Run("Dedup Summary", func(t *testing.T) {
+		var buffer bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithDedup(time.Minute))
+		clock := newFakeClock()
+		formatter.nowFunc = clock.Now
+		tee := NewTee(formatter, TeeSink{Writer: &buffer, Labels: map[string]string{"export": "ops"}})
+
+		logger := logrus.New()
+		logger.SetOutput(io.Discard)
+		logger.AddHook(tee)
+		logger.Error("boom")
+		logger.Error("boom")
+		buffer.Reset()
+
+		clock.Advance(time.Minute)
+		logger.Info("other")
+		assert.Equal(t, `{"logging.googleapis.com/labels":{"export":"ops"},"message":"boom","repeated":1,"severity":"Error"}`+"\n"+`{"logging.googleapis.com/labels":{"export":"ops"},"message":"other","severity":"Info"}`+"\n", buffer.String())
+	})
+
+	t.Run("Marshal Fallback", func(t *testing.T) {
+		var first, second bytes.Buffer
+		formatter := New(WithDisableTimestamp(true), WithMarshalFallback(true), WithValueMarshaler(func(key string, value interface{}) (interface{}, error) {
+			return nil, errors.New("bad value")
+		}))
+		tee := NewTee(formatter, TeeSink{Writer: &first}, TeeSink{Writer: &second, Labels: map[string]string{"export": "ops"}})
+
+		e := logrus.NewEntry(logrus.New()).WithField("key", "value")
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, `{"_format_error":"could not marshal field \"key\": bad value","message":"test","severity":"Error"}`+"\n", first.String())
+		assert.Equal(t, first.String(), second.String())
+
+		formatter.MarshalFallback = false
+		assert.NotNil(t, tee.Fire(e))
+	})
+
+	t.Run("Callbacks", func(t *testing.T) {
+		var first, second bytes.Buffer
+		var sizes []int
+		var oversized []int
+		formatter := New(
+			WithDisableTimestamp(true),
+			WithOnFormat(func(severity logging.Severity, size int) {
+				assert.Equal(t, logging.Info, severity)
+				sizes = append(sizes, size)
+			}),
+			WithOnOversized(60, func(size int, entry *logrus.Entry) {
+				oversized = append(oversized, size)
+			}),
+		)
+		tee := NewTee(formatter, TeeSink{Writer: &first}, TeeSink{Writer: &second, Labels: map[string]string{"export": "ops"}})
+
+		e := logrus.NewEntry(logrus.New())
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, []int{first.Len(), second.Len()}, sizes)
+		assert.Equal(t, []int{second.Len()}, oversized)
+	})
+
+	t.Run("Dropped", func(t *testing.T) {
+		var buffer bytes.Buffer
+		tee := NewTee(New(WithMinLevel(logrus.InfoLevel)), TeeSink{Writer: &buffer})
+
+		e := logrus.NewEntry(logrus.New())
+		e.Level = logrus.DebugLevel
+		require.Nil(t, tee.Fire(e))
+		assert.Equal(t, "", buffer.String())
+	})
+}
@@ -0,0 +1,88 @@
+// Package testutil provides helpers for testing code that logs with the formatter.
+//
+// A `Capture` installs the formatter on a logrus logger and records each formatted entry so that
+// tests can assert on the decoded entries instead of parsing the output by hand.
+package testutil
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+)
+
+// Capture records the entries that a logrus logger writes.
+type Capture struct {
+	mutex   sync.Mutex
+	lines   [][]byte
+	entries []*gcfstructuredlogformatter.Entry
+}
+
+// NewCapture installs the formatter on the logger and captures everything that the logger writes.
+//
+// If the formatter is nil, then a default formatter is used.
+func NewCapture(logger *logrus.Logger, formatter *gcfstructuredlogformatter.Formatter) *Capture {
+	if formatter == nil {
+		formatter = gcfstructuredlogformatter.New()
+	}
+	c := &Capture{}
+	logger.SetFormatter(formatter)
+	logger.SetOutput(c)
+	return c
+}
+
+// Write records the formatted entries.
+//
+// logrus writes each entry with a single call, but the formatter may write summary lines ahead of it (see `WithDedup`),
+// so each line is decoded as one entry; empty writes are ignored.
+func (c *Capture) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// The formatter dropped the entry.
+		return 0, nil
+	}
+
+	var lines [][]byte
+	var entries []*gcfstructuredlogformatter.Entry
+	for _, contents := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(contents) == 0 {
+			continue
+		}
+		entry, err := gcfstructuredlogformatter.ParseEntry(contents)
+		if err != nil {
+			return 0, err
+		}
+		line := make([]byte, len(contents))
+		copy(line, contents)
+		lines = append(lines, line)
+		entries = append(entries, entry)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lines = append(c.lines, lines...)
+	c.entries = append(c.entries, entries...)
+	return len(p), nil
+}
+
+// Entries returns the decoded entries, in the order in which they were written.
+func (c *Capture) Entries() []*gcfstructuredlogformatter.Entry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*gcfstructuredlogformatter.Entry(nil), c.entries...)
+}
+
+// Lines returns the raw formatted entries, in the order in which they were written.
+func (c *Capture) Lines() [][]byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([][]byte(nil), c.lines...)
+}
+
+// Reset discards the captured entries.
+func (c *Capture) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.lines = nil
+	c.entries = nil
+}
@@ -0,0 +1,74 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+)
+
+func TestCapture(t *testing.T) {
+	logger := logrus.New()
+	capture := NewCapture(logger, gcfstructuredlogformatter.New(
+		gcfstructuredlogformatter.WithLabels(map[string]string{"service": "test"}),
+	))
+
+	logger.WithField("user", "alice").Info("first")
+	logger.Error("second")
+
+	entries := capture.Entries()
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "Info", entries[0].Severity)
+	assert.Equal(t, "first", entries[0].Message)
+	assert.Equal(t, map[string]string{"service": "test"}, entries[0].Labels)
+	assert.Equal(t, "alice", entries[0].Fields["user"])
+
+	assert.Equal(t, "Error", entries[1].Severity)
+	assert.Equal(t, "second", entries[1].Message)
+	assert.Equal(t, map[string]string{"service": "test"}, entries[1].Labels)
+
+	require.Len(t, capture.Lines(), 2)
+
+	capture.Reset()
+	assert.Empty(t, capture.Entries())
+	assert.Empty(t, capture.Lines())
+}
+
+func TestCaptureDefaultFormatter(t *testing.T) {
+	logger := logrus.New()
+	capture := NewCapture(logger, nil)
+
+	logger.Warn("test")
+
+	entries := capture.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "Warning", entries[0].Severity)
+	assert.Nil(t, entries[0].Labels)
+}
+
+func TestCaptureDroppedEntry(t *testing.T) {
+	logger := logrus.New()
+	capture := NewCapture(logger, gcfstructuredlogformatter.New(gcfstructuredlogformatter.WithMinLevel(logrus.WarnLevel)))
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+
+	entries := capture.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "kept", entries[0].Message)
+}
+
+func TestCaptureSeveralLines(t *testing.T) {
+	capture := &Capture{}
+	_, err := capture.Write([]byte(`{"message":"summary","repeated":3,"severity":"Error"}` + "\n" + `{"message":"next","severity":"Info"}` + "\n"))
+	require.Nil(t, err)
+
+	entries := capture.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "summary", entries[0].Message)
+	assert.Equal(t, "next", entries[1].Message)
+	assert.Equal(t, []byte(`{"message":"next","severity":"Info"}`+"\n"), capture.Lines()[1])
+}
@@ -0,0 +1,16 @@
+package gcfstructuredlogformatter
+
+import (
+	"time"
+)
+
+// formatTime returns the value to write for a time.
+//
+// If `TimeLayout` is set, then the time is converted to UTC and written with that layout;
+// otherwise, the time is written as `encoding/json` does (RFC 3339 in the time's own zone).
+func (f *Formatter) formatTime(value time.Time) interface{} {
+	if f.TimeLayout == "" {
+		return value
+	}
+	return value.UTC().Format(f.TimeLayout)
+}
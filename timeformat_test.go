@@ -0,0 +1,54 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatWithTimeLayout(t *testing.T) {
+	logger := logrus.New()
+	zone := time.FixedZone("EST", -5*60*60)
+	createdAt := time.Date(2024, time.June, 15, 3, 30, 15, 123456789, zone)
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"created_at":"2024-06-15T03:30:15.123456789-05:00","message":"test","nested":{"updated_at":"2024-06-15T03:30:15-05:00"},"pointer":"2024-06-15T03:30:15.123456789-05:00","severity":"Info","time":"2024-06-15T08:30:15.123456789Z"}` + "\n"),
+		},
+		{
+			description: "RFC 3339 Nano",
+			options:     []Option{WithTimeLayout(time.RFC3339Nano)},
+			output:      []byte(`{"created_at":"2024-06-15T08:30:15.123456789Z","message":"test","nested":{"updated_at":"2024-06-15T08:30:15Z"},"pointer":"2024-06-15T08:30:15.123456789Z","severity":"Info","time":"2024-06-15T08:30:15.123456789Z"}` + "\n"),
+		},
+		{
+			description: "Custom",
+			options:     []Option{WithTimeLayout(time.DateOnly)},
+			output:      []byte(`{"created_at":"2024-06-15","message":"test","nested":{"updated_at":"2024-06-15"},"pointer":"2024-06-15","severity":"Info","time":"2024-06-15T08:30:15.123456789Z"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithFields(logrus.Fields{
+				"created_at": createdAt,
+				"pointer":    &createdAt,
+				"nested":     map[string]interface{}{"updated_at": createdAt.Truncate(time.Second)},
+			})
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+			e.Time = createdAt
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
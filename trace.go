@@ -0,0 +1,280 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// CloudTraceHeader is the name of the Google Cloud trace header.
+const CloudTraceHeader = "X-Cloud-Trace-Context"
+
+// TraceContext is the trace information associated with a log entry.
+//
+// This may be provided through the context (see `ContextKeyTraceContext`) when there is no OpenTelemetry span available.
+type TraceContext struct {
+	TraceID string // The trace ID, as 32 hexadecimal characters.
+	SpanID  string // The span ID, as 16 hexadecimal characters; this is optional.
+	Sampled bool   // Whether or not the trace was sampled.
+}
+
+// TraceFromCloudHeader parses the value of an "X-Cloud-Trace-Context" header.
+//
+// The header has the form "TRACE_ID/SPAN_ID;o=OPTIONS", where the span ID is a decimal number
+// and the span ID and options are both optional.
+// The span ID is converted into the hexadecimal form that Google Cloud Logging expects.
+//
+// If the header does not contain a trace ID, then all of the return values are empty.
+func TraceFromCloudHeader(header string) (traceID, spanID string, sampled bool) {
+	value, options, _ := strings.Cut(header, ";")
+	traceID, spanValue, _ := strings.Cut(value, "/")
+	traceID = strings.TrimSpace(traceID)
+	if traceID == "" {
+		return "", "", false
+	}
+
+	if spanValue = strings.TrimSpace(spanValue); spanValue != "" {
+		if spanNumber, err := strconv.ParseUint(spanValue, 10, 64); err == nil && spanNumber != 0 {
+			spanID = fmt.Sprintf("%016x", spanNumber)
+		}
+	}
+
+	for _, option := range strings.Split(options, ";") {
+		if strings.TrimSpace(option) == "o=1" {
+			sampled = true
+		}
+	}
+	return traceID, spanID, sampled
+}
+
+// TraceFromTraceparent parses the value of a W3C "traceparent" header.
+//
+// The header has the form "VERSION-TRACE_ID-SPAN_ID-FLAGS", where each part is lowercase hexadecimal.
+// See https://www.w3.org/TR/trace-context/#traceparent-header for details.
+//
+// If the header is malformed, then an error is returned.
+func TraceFromTraceparent(traceparent string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: expected 4 parts, got %d", traceparent, len(parts))
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if !isLowerHex(version, 2) || version == "ff" {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: invalid version %q", traceparent, version)
+	}
+	// Version 00 has exactly 4 parts; later versions may add more parts, which are ignored.
+	if version == "00" && len(parts) != 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: expected 4 parts, got %d", traceparent, len(parts))
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: invalid trace ID %q", traceparent, traceID)
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: invalid span ID %q", traceparent, spanID)
+	}
+	if !isLowerHex(flags, 2) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: invalid flags %q", traceparent, flags)
+	}
+	flagValue, _ := strconv.ParseUint(flags, 16, 8)
+
+	return TraceContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: flagValue&0x01 != 0,
+	}, nil
+}
+
+// WithTrace returns a copy of the context that carries the given trace and span IDs (see `ContextKeyTraceContext`).
+//
+// This is useful when the IDs come from somewhere other than a live OpenTelemetry span, such as a message that a
+// background worker received; an OpenTelemetry span in the context still takes precedence.
+// The trace ID must be 32 hexadecimal characters and the span ID must be 16 hexadecimal characters (or empty).
+// If the trace ID is invalid, then the context is returned as-is; if only the span ID is invalid, then it is left out.
+func WithTrace(ctx context.Context, traceID, spanID string) context.Context {
+	traceID = strings.ToLower(traceID)
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return ctx
+	}
+	spanID = strings.ToLower(spanID)
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		spanID = ""
+	}
+	return context.WithValue(ctx, ContextKeyTraceContext, TraceContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+	})
+}
+
+// isLowerHex returns true if the value is exactly the given number of lowercase hexadecimal characters.
+func isLowerHex(value string, length int) bool {
+	if len(value) != length {
+		return false
+	}
+	for _, c := range value {
+		if !('0' <= c && c <= '9') && !('a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// TracePrecedence determines which trace is used when the context has both an OpenTelemetry span and
+// a trace from one of the context keys (`ContextKeyTraceContext`, `ContextKeyTraceparent`, or `ContextKeyTrace`).
+type TracePrecedence int
+
+const (
+	// PreferOtel uses the OpenTelemetry span's trace, if it has one.
+	// This is the default.
+	PreferOtel TracePrecedence = iota
+	// PreferContextKey uses the trace from the context keys, if there is one.
+	// This is useful while migrating to OpenTelemetry, when the context keys are set by code that knows the real trace.
+	PreferContextKey
+)
+
+// traceFromContext returns the trace information from the context.
+//
+// The trace comes from either an OpenTelemetry span context with a trace ID (its span ID is only used if it has one)
+// or the context keys; the precedence determines which one is tried first.
+func traceFromContext(ctx context.Context, precedence TracePrecedence) (TraceContext, bool) {
+	if precedence == PreferContextKey {
+		if traceContext, okay := traceFromContextKeys(ctx); okay {
+			return traceContext, true
+		}
+		return traceFromSpan(ctx)
+	}
+	if traceContext, okay := traceFromSpan(ctx); okay {
+		return traceContext, true
+	}
+	return traceFromContextKeys(ctx)
+}
+
+// traceFromSpan returns the trace information from the OpenTelemetry span context, if it has a trace ID.
+func traceFromSpan(ctx context.Context) (TraceContext, bool) {
+	spanContext := trace.SpanFromContext(ctx).SpanContext()
+	if !spanContext.HasTraceID() {
+		return TraceContext{}, false
+	}
+	traceContext := TraceContext{
+		TraceID: spanContext.TraceID().String(),
+		Sampled: spanContext.IsSampled(),
+	}
+	if spanContext.HasSpanID() {
+		traceContext.SpanID = spanContext.SpanID().String()
+	}
+	return traceContext, true
+}
+
+// traceFromContextKeys returns the trace information from the context keys.
+//
+// The `ContextKeyTraceContext` value is used first, followed by the `ContextKeyTraceparent` value and finally
+// the legacy `ContextKeyTrace` value.
+func traceFromContextKeys(ctx context.Context) (TraceContext, bool) {
+	switch traceContext := ctx.Value(ContextKeyTraceContext).(type) {
+	case TraceContext:
+		if traceContext.TraceID != "" {
+			return traceContext, true
+		}
+	case *TraceContext:
+		if traceContext != nil && traceContext.TraceID != "" {
+			return *traceContext, true
+		}
+	}
+
+	if traceparent, okay := ctx.Value(ContextKeyTraceparent).(string); okay {
+		if traceContext, err := TraceFromTraceparent(traceparent); err == nil {
+			return traceContext, true
+		}
+	}
+
+	if legacyTrace, okay := ctx.Value(ContextKeyTrace).(string); okay && isValidTrace(legacyTrace) {
+		return TraceContext{TraceID: legacyTrace}, true
+	}
+	return TraceContext{}, false
+}
+
+// isValidTrace returns true if the value is a trace ID or a fully-qualified trace ("projects/PROJECT_ID/traces/TRACE_ID").
+func isValidTrace(value string) bool {
+	traceID := value
+	if strings.HasPrefix(value, "projects/") {
+		var projectID string
+		var okay bool
+		projectID, traceID, okay = strings.Cut(strings.TrimPrefix(value, "projects/"), "/traces/")
+		if !okay || projectID == "" || strings.Contains(projectID, "/") {
+			return false
+		}
+	}
+	return isLowerHex(strings.ToLower(traceID), 32)
+}
+
+// SpanIDMode determines what happens to a span ID that is not the 16 hexadecimal characters that
+// Google Cloud Logging expects (for example, a shorter ID from a custom propagator).
+type SpanIDMode int
+
+const (
+	// SpanIDPassthrough writes the span ID as-is.
+	// This is the default.
+	SpanIDPassthrough SpanIDMode = iota
+	// SpanIDPad left-pads a short hexadecimal span ID with zeros; any other malformed span ID is omitted.
+	SpanIDPad
+	// SpanIDOmit omits a malformed span ID.
+	SpanIDOmit
+)
+
+// spanID returns the span ID to write, taking the `SpanIDMode` into account.
+//
+// If the span ID is omitted, then this returns an empty string.
+func (f *Formatter) spanID(spanID string) string {
+	if f.SpanIDMode == SpanIDPassthrough || spanID == "" {
+		return spanID
+	}
+	spanID = strings.ToLower(spanID)
+	if f.SpanIDMode == SpanIDPad && len(spanID) < 16 {
+		spanID = strings.Repeat("0", 16-len(spanID)) + spanID
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return ""
+	}
+	return spanID
+}
+
+// traceKey returns the key for the trace.
+func (f *Formatter) traceKey() string {
+	if f.TraceKey != "" {
+		return f.TraceKey
+	}
+	return TraceKey
+}
+
+// spanKey returns the key for the span ID.
+func (f *Formatter) spanKey() string {
+	if f.SpanIDKey != "" {
+		return f.SpanIDKey
+	}
+	return SpanKey
+}
+
+// bareTraceID returns the trace ID without the "projects/PROJECT_ID/traces/" prefix, if it has one.
+func bareTraceID(traceID string) string {
+	if !strings.HasPrefix(traceID, "projects/") {
+		return traceID
+	}
+	if index := strings.LastIndex(traceID, "/traces/"); index >= 0 {
+		return traceID[index+len("/traces/"):]
+	}
+	return traceID
+}
+
+// qualifyTrace returns the trace value that Google Cloud Logging expects.
+//
+// If the formatter has a project ID, then the trace ID is returned as "projects/PROJECT_ID/traces/TRACE_ID";
+// otherwise, the trace ID is returned as-is.
+func (f *Formatter) qualifyTrace(traceID string) string {
+	if f.ProjectID == "" || strings.HasPrefix(traceID, "projects/") {
+		return traceID
+	}
+	return "projects/" + f.ProjectID + "/traces/" + traceID
+}
@@ -0,0 +1,842 @@
+package gcfstructuredlogformatter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTraceFromCloudHeader(t *testing.T) {
+	rows := []struct {
+		description string
+		input       string
+		traceID     string
+		spanID      string
+		sampled     bool
+	}{
+		{
+			description: "Empty",
+			input:       "",
+		},
+		{
+			description: "Sampled",
+			input:       "105445aa7843bc8bf206b12000100000/1;o=1",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			spanID:      "0000000000000001",
+			sampled:     true,
+		},
+		{
+			description: "Not Sampled",
+			input:       "105445aa7843bc8bf206b12000100000/18446744073709551615;o=0",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			spanID:      "ffffffffffffffff",
+			sampled:     false,
+		},
+		{
+			description: "No Options",
+			input:       "105445aa7843bc8bf206b12000100000/255",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			spanID:      "00000000000000ff",
+		},
+		{
+			description: "Missing Span",
+			input:       "105445aa7843bc8bf206b12000100000;o=1",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			sampled:     true,
+		},
+		{
+			description: "Empty Span",
+			input:       "105445aa7843bc8bf206b12000100000/;o=1",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			sampled:     true,
+		},
+		{
+			description: "Bogus Span",
+			input:       "105445aa7843bc8bf206b12000100000/abc",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+		},
+		{
+			description: "Missing Trace",
+			input:       "/123;o=1",
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			traceID, spanID, sampled := TraceFromCloudHeader(row.input)
+			assert.Equal(t, row.traceID, traceID)
+			assert.Equal(t, row.spanID, spanID)
+			assert.Equal(t, row.sampled, sampled)
+		})
+	}
+}
+
+func TestTraceFromTraceparent(t *testing.T) {
+	rows := []struct {
+		description string
+		input       string
+		output      TraceContext
+		err         bool
+	}{
+		{
+			description: "Sampled",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-01",
+			output:      TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "09158d8185d3c3af", Sampled: true},
+		},
+		{
+			description: "Not Sampled",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-00",
+			output:      TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "09158d8185d3c3af"},
+		},
+		{
+			description: "Future Version with Extra Parts",
+			input:       "01-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-03-extra",
+			output:      TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "09158d8185d3c3af", Sampled: true},
+		},
+		{
+			description: "Empty",
+			input:       "",
+			err:         true,
+		},
+		{
+			description: "Too Few Parts",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af",
+			err:         true,
+		},
+		{
+			description: "Version 00 with Extra Parts",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-01-extra",
+			err:         true,
+		},
+		{
+			description: "Invalid Version",
+			input:       "ff-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-01",
+			err:         true,
+		},
+		{
+			description: "Bad Version Length",
+			input:       "000-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-01",
+			err:         true,
+		},
+		{
+			description: "Bad Trace ID Length",
+			input:       "00-105445aa7843bc8bf206b120001000-09158d8185d3c3af-01",
+			err:         true,
+		},
+		{
+			description: "Uppercase Trace ID",
+			input:       "00-105445AA7843BC8BF206B12000100000-09158d8185d3c3af-01",
+			err:         true,
+		},
+		{
+			description: "Zero Trace ID",
+			input:       "00-00000000000000000000000000000000-09158d8185d3c3af-01",
+			err:         true,
+		},
+		{
+			description: "Bad Span ID Length",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3-01",
+			err:         true,
+		},
+		{
+			description: "Zero Span ID",
+			input:       "00-105445aa7843bc8bf206b12000100000-0000000000000000-01",
+			err:         true,
+		},
+		{
+			description: "Bad Flags",
+			input:       "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-1",
+			err:         true,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			output, err := TraceFromTraceparent(row.input)
+			if row.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			assert.Equal(t, row.output, output)
+		})
+	}
+}
+
+func TestFormatWithTrace(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "OpenTelemetry Span",
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+					SpanID:  trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "OpenTelemetry Span Sampled",
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+					SpanID:     trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+					TraceFlags: trace.FlagsSampled,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "OpenTelemetry Trace without Span",
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    trace.TraceID{0x10, 0x54, 0x45, 0xaa, 0x78, 0x43, 0xbc, 0x8b, 0xf2, 0x06, 0xb1, 0x20, 0x00, 0x10, 0x00, 0x00},
+					TraceFlags: trace.FlagsSampled,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "OpenTelemetry Span without Trace",
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					SpanID: trace.SpanID{0, 0, 0, 0, 0, 0, 0, 1},
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Cloud Trace Header",
+			input: func() *logrus.Entry {
+				traceID, spanID, sampled := TraceFromCloudHeader("105445aa7843bc8bf206b12000100000/1;o=1")
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: traceID, SpanID: spanID, Sampled: sampled})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Cloud Trace Header without Span",
+			input: func() *logrus.Entry {
+				traceID, spanID, sampled := TraceFromCloudHeader("105445aa7843bc8bf206b12000100000;o=0")
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, &TraceContext{TraceID: traceID, SpanID: spanID, Sampled: sampled})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty Trace Context",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Traceparent",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceparent, "00-105445aa7843bc8bf206b12000100000-09158d8185d3c3af-01")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Malformed Traceparent",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceparent, "00-105445aa7843bc8bf206b12000100000-01")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Legacy Trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "105445aa7843bc8bf206b12000100000")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Legacy Qualified Trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "projects/my-project/traces/105445aa7843bc8bf206b12000100000")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Info with bogus trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, 123456)
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Info with malformed trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "not a trace")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Info with malformed qualified trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "projects/my-project/105445aa7843bc8bf206b12000100000")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Trace Context over Legacy Trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "105445aa7843bc8bf206b12000100000")
+				ctx = WithTrace(ctx, "205445aa7843bc8bf206b12000100000", "")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"205445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New()
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithProjectID(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		projectID   string
+		traceID     string
+		output      []byte
+	}{
+		{
+			description: "No Project ID",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			output:      []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Project ID",
+			projectID:   "my-project",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			output:      []byte(`{"logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Project ID with Qualified Trace",
+			projectID:   "my-project",
+			traceID:     "projects/other-project/traces/105445aa7843bc8bf206b12000100000",
+			output:      []byte(`{"logging.googleapis.com/trace":"projects/other-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: row.traceID})
+			e := logger.WithContext(ctx)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New()
+			formatter.ProjectID = row.projectID
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithFlatTraceFields(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "No Trace",
+			input: func() *logrus.Entry {
+				e := logger.WithContext(context.Background())
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Span Context",
+			input: func() *logrus.Entry {
+				traceID, _ := trace.TraceIDFromHex("105445aa7843bc8bf206b12000100000")
+				spanID, _ := trace.SpanIDFromHex("09158d8185d3c3af")
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    traceID,
+					SpanID:     spanID,
+					TraceFlags: trace.FlagsSampled,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info","span_id":"09158d8185d3c3af","trace_id":"105445aa7843bc8bf206b12000100000"}` + "\n"),
+		},
+		{
+			description: "Qualified Legacy Trace",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTrace, "projects/other-project/traces/105445aa7843bc8bf206b12000100000")
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"projects/other-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info","trace_id":"105445aa7843bc8bf206b12000100000"}` + "\n"),
+		},
+		{
+			description: "Trace Context without Span",
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000"})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info","trace_id":"105445aa7843bc8bf206b12000100000"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(WithProjectID("my-project"), WithFlatTraceFields(true))
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithTraceKeys(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		options     []Option
+		output      []byte
+	}{
+		{
+			description: "Default",
+			output:      []byte(`{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Empty Overrides",
+			options:     []Option{WithTraceKey(""), WithSpanIDKey("")},
+			output:      []byte(`{"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Custom",
+			options:     []Option{WithTraceKey("traceId"), WithSpanIDKey("spanId")},
+			output:      []byte(`{"logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info","spanId":"0000000000000001","traceId":"105445aa7843bc8bf206b12000100000"}` + "\n"),
+		},
+		{
+			description: "Custom Ordered",
+			options:     []Option{WithTraceKey("traceId"), WithSpanIDKey("spanId"), WithOrderedKeys(true)},
+			output:      []byte(`{"severity":"Info","message":"test","traceId":"105445aa7843bc8bf206b12000100000","spanId":"0000000000000001","logging.googleapis.com/trace_sampled":false}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "0000000000000001"})
+			e := logger.WithContext(ctx)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(row.options...)
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, row.output, result)
+		})
+	}
+}
+
+func TestFormatWithTraceFlagsAndState(t *testing.T) {
+	logger := logrus.New()
+	traceID, _ := trace.TraceIDFromHex("105445aa7843bc8bf206b12000100000")
+	spanID, _ := trace.SpanIDFromHex("09158d8185d3c3af")
+	traceState, err := trace.ParseTraceState("vendor=value,other=1")
+	require.Nil(t, err)
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Disabled",
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    traceID,
+					SpanID:     spanID,
+					TraceFlags: trace.FlagsSampled,
+					TraceState: traceState,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Trace State",
+			options:     []Option{WithTraceFlagsAndState(true)},
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    traceID,
+					SpanID:     spanID,
+					TraceFlags: trace.FlagsSampled,
+					TraceState: traceState,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info","trace_flags":"01","trace_state":"vendor=value,other=1"}` + "\n"),
+		},
+		{
+			description: "No Trace State",
+			options:     []Option{WithTraceFlagsAndState(true)},
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID: traceID,
+					SpanID:  spanID,
+				}))
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info","trace_flags":"00"}` + "\n"),
+		},
+		{
+			description: "No Span Context",
+			options:     []Option{WithTraceFlagsAndState(true)},
+			input: func() *logrus.Entry {
+				ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", Sampled: true})
+				e := logger.WithContext(ctx)
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Ordered",
+			options:     []Option{WithTraceFlagsAndState(true), WithOrderedKeys(true)},
+			input: func() *logrus.Entry {
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+					TraceID:    traceID,
+					SpanID:     spanID,
+					TraceFlags: trace.FlagsSampled,
+					TraceState: traceState,
+				}))
+				e := logger.WithContext(ctx).WithField("key", "value")
+				e.Message = "test"
+				e.Level = logrus.InfoLevel
+				return e
+			}(),
+			output: []byte(`{"severity":"Info","message":"test","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace_sampled":true,"trace_flags":"01","trace_state":"vendor=value,other=1","key":"value"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithTracePrecedence(t *testing.T) {
+	logger := logrus.New()
+	traceID, _ := trace.TraceIDFromHex("105445aa7843bc8bf206b12000100000")
+	spanID, _ := trace.SpanIDFromHex("09158d8185d3c3af")
+
+	// input returns an entry whose context has both an OpenTelemetry span and the given context key value.
+	input := func(key contextKey, value interface{}) *logrus.Entry {
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+		e := logger.WithContext(context.WithValue(ctx, key, value))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+		return e
+	}
+
+	rows := []struct {
+		description string
+		options     []Option
+		input       *logrus.Entry
+		output      []byte
+	}{
+		{
+			description: "Default",
+			input:       input(ContextKeyTrace, "205445aa7843bc8bf206b12000100000"),
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Prefer Otel",
+			options:     []Option{WithTracePrecedence(PreferOtel)},
+			input:       input(ContextKeyTrace, "205445aa7843bc8bf206b12000100000"),
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Prefer Context Key",
+			options:     []Option{WithTracePrecedence(PreferContextKey)},
+			input:       input(ContextKeyTrace, "205445aa7843bc8bf206b12000100000"),
+			output:      []byte(`{"logging.googleapis.com/trace":"205445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Prefer Context Key with Trace Context",
+			options:     []Option{WithTracePrecedence(PreferContextKey)},
+			input:       input(ContextKeyTraceContext, TraceContext{TraceID: "205445aa7843bc8bf206b12000100000", SpanID: "0000000000000002"}),
+			output:      []byte(`{"logging.googleapis.com/spanId":"0000000000000002","logging.googleapis.com/trace":"205445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Prefer Context Key with Invalid Trace",
+			options:     []Option{WithTracePrecedence(PreferContextKey)},
+			input:       input(ContextKeyTrace, "not a trace"),
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Prefer Context Key without Flags",
+			options:     []Option{WithTracePrecedence(PreferContextKey), WithTraceFlagsAndState(true)},
+			input:       input(ContextKeyTrace, "205445aa7843bc8bf206b12000100000"),
+			output:      []byte(`{"logging.googleapis.com/trace":"205445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			formatter := New(row.options...)
+			result, err := formatter.Format(row.input)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestWithTrace(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		traceID     string
+		spanID      string
+		output      []byte
+	}{
+		{
+			description: "Trace and Span",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			spanID:      "09158d8185d3c3af",
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Uppercase",
+			traceID:     "105445AA7843BC8BF206B12000100000",
+			spanID:      "09158D8185D3C3AF",
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "No Span",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			output:      []byte(`{"logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Invalid Span",
+			traceID:     "105445aa7843bc8bf206b12000100000",
+			spanID:      "1234",
+			output:      []byte(`{"logging.googleapis.com/trace":"projects/my-project/traces/105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Short Trace",
+			traceID:     "105445aa7843bc8b",
+			spanID:      "09158d8185d3c3af",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Not Hexadecimal",
+			traceID:     "105445aa7843bc8bf206b1200010000z",
+			spanID:      "09158d8185d3c3af",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Zero Trace",
+			traceID:     "00000000000000000000000000000000",
+			output:      []byte(`{"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			e := logger.WithContext(WithTrace(context.Background(), row.traceID, row.spanID))
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(WithProjectID("my-project"))
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+}
+
+func TestFormatWithSpanIDMode(t *testing.T) {
+	logger := logrus.New()
+	rows := []struct {
+		description string
+		spanIDMode  SpanIDMode
+		spanID      string
+		output      []byte
+	}{
+		{
+			description: "Passthrough Short Span",
+			spanIDMode:  SpanIDPassthrough,
+			spanID:      "1234",
+			output:      []byte(`{"logging.googleapis.com/spanId":"1234","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Pad Short Span",
+			spanIDMode:  SpanIDPad,
+			spanID:      "1234",
+			output:      []byte(`{"logging.googleapis.com/spanId":"0000000000001234","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Pad Uppercase Span",
+			spanIDMode:  SpanIDPad,
+			spanID:      "ABCD",
+			output:      []byte(`{"logging.googleapis.com/spanId":"000000000000abcd","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Pad Not Hexadecimal",
+			spanIDMode:  SpanIDPad,
+			spanID:      "123z",
+			output:      []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Pad Long Span",
+			spanIDMode:  SpanIDPad,
+			spanID:      "09158d8185d3c3af00",
+			output:      []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Pad Zero Span",
+			spanIDMode:  SpanIDPad,
+			spanID:      "0",
+			output:      []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Omit Short Span",
+			spanIDMode:  SpanIDOmit,
+			spanID:      "1234",
+			output:      []byte(`{"logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Omit Valid Span",
+			spanIDMode:  SpanIDOmit,
+			spanID:      "09158d8185d3c3af",
+			output:      []byte(`{"logging.googleapis.com/spanId":"09158d8185d3c3af","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: row.spanID})
+			e := logger.WithContext(ctx)
+			e.Message = "test"
+			e.Level = logrus.InfoLevel
+
+			formatter := New(WithSpanIDMode(row.spanIDMode))
+			result, err := formatter.Format(e)
+			require.Nil(t, err)
+			assert.Equal(t, string(row.output), string(result))
+		})
+	}
+
+	t.Run("Flat Trace Fields", func(t *testing.T) {
+		ctx := context.WithValue(context.Background(), ContextKeyTraceContext, TraceContext{TraceID: "105445aa7843bc8bf206b12000100000", SpanID: "1234"})
+		e := logger.WithContext(ctx)
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithSpanIDMode(SpanIDPad), WithFlatTraceFields(true))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"logging.googleapis.com/spanId":"0000000000001234","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":false,"message":"test","severity":"Info","span_id":"0000000000001234","trace_id":"105445aa7843bc8bf206b12000100000"}`+"\n", string(result))
+	})
+}
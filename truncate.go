@@ -0,0 +1,62 @@
+package gcfstructuredlogformatter
+
+import (
+	"unicode/utf8"
+)
+
+// TruncatedMarker is appended to strings that have been truncated.
+const TruncatedMarker = "…(truncated)"
+
+// cutString returns at most the first `size` bytes of the string, without splitting a UTF-8 character.
+func cutString(value string, size int) string {
+	if size >= len(value) {
+		return value
+	}
+	if size < 0 {
+		size = 0
+	}
+	for size > 0 && !utf8.RuneStart(value[size]) {
+		size--
+	}
+	return value[:size]
+}
+
+// truncateString returns the string cut to at most `size` bytes followed by `TruncatedMarker`.
+//
+// If the size is not positive or the string is short enough, then the string is returned as-is.
+func truncateString(value string, size int) string {
+	if size <= 0 || len(value) <= size {
+		return value
+	}
+	return cutString(value, size) + TruncatedMarker
+}
+
+// shrink re-encodes an entry that is larger than `MaxEntryBytes`.
+//
+// All of the logrus fields are dropped and the message is marked as truncated; if the entry is
+// still too large, then the message is cut until the entry fits (or the message is empty).
+func (f *Formatter) shrink(buffer *encodeBuffer, mapEntry map[string]interface{}) error {
+	reservedKeys := f.reservedKeys(mapEntry)
+	for key := range mapEntry {
+		if !containsKey(reservedKeys, key) {
+			delete(mapEntry, key)
+		}
+	}
+
+	messageKey := f.messageKey()
+	message, _ := mapEntry[messageKey].(string)
+	if messageKey != "" {
+		mapEntry[messageKey] = message + TruncatedMarker
+	}
+	if err := f.encode(buffer, mapEntry); err != nil {
+		return err
+	}
+	for excess := buffer.Len() - f.MaxEntryBytes; excess > 0 && message != "" && messageKey != ""; excess = buffer.Len() - f.MaxEntryBytes {
+		message = cutString(message, len(message)-excess)
+		mapEntry[messageKey] = message + TruncatedMarker
+		if err := f.encode(buffer, mapEntry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package gcfstructuredlogformatter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateString(t *testing.T) {
+	rows := []struct {
+		description string
+		input       string
+		size        int
+		output      string
+	}{
+		{
+			description: "Disabled",
+			input:       "hello",
+			size:        0,
+			output:      "hello",
+		},
+		{
+			description: "Short Enough",
+			input:       "hello",
+			size:        5,
+			output:      "hello",
+		},
+		{
+			description: "ASCII",
+			input:       "hello",
+			size:        3,
+			output:      "hel" + TruncatedMarker,
+		},
+		{
+			description: "Two-Byte Character",
+			input:       "héllo",
+			size:        2,
+			output:      "h" + TruncatedMarker,
+		},
+		{
+			description: "Three-Byte Characters",
+			input:       "日本語",
+			size:        4,
+			output:      "日" + TruncatedMarker,
+		},
+		{
+			description: "Four-Byte Characters",
+			input:       "😀😀",
+			size:        7,
+			output:      "😀" + TruncatedMarker,
+		},
+		{
+			description: "Smaller than a Character",
+			input:       "日本語",
+			size:        1,
+			output:      TruncatedMarker,
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			result := truncateString(row.input, row.size)
+			assert.Equal(t, row.output, result)
+			assert.True(t, utf8.ValidString(result))
+		})
+	}
+}
+
+func TestFormatWithMaxFieldBytes(t *testing.T) {
+	logger := logrus.New()
+	e := logger.WithFields(logrus.Fields{
+		"short":  "abc",
+		"long":   "日本語日本語",
+		"count":  123456789,
+		"nested": map[string]interface{}{"long": "abcdefghij"},
+	})
+	e.Message = "héllo wörld"
+	e.Level = logrus.InfoLevel
+
+	formatter := New(WithMaxFieldBytes(7))
+	result, err := formatter.Format(e)
+	require.Nil(t, err)
+	assert.True(t, json.Valid(result))
+	assert.Equal(t, `{"count":123456789,"long":"日本`+TruncatedMarker+`","message":"héllo `+TruncatedMarker+`","nested":{"long":"abcdefg`+TruncatedMarker+`"},"severity":"Info","short":"abc"}`+"\n", string(result))
+}
+
+func TestFormatWithMaxEntryBytes(t *testing.T) {
+	logger := logrus.New()
+
+	t.Run("Small Entry", func(t *testing.T) {
+		e := logger.WithField("prop", "value")
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithMaxEntryBytes(1024))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"message":"test","prop":"value","severity":"Info"}`+"\n", string(result))
+	})
+
+	t.Run("Large Field", func(t *testing.T) {
+		e := logger.WithField("body", strings.Repeat("x", 2048))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithMaxEntryBytes(1024), WithLabels(map[string]string{"key": "value"}))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"logging.googleapis.com/labels":{"key":"value"},"message":"test`+TruncatedMarker+`","severity":"Info"}`+"\n", string(result))
+	})
+
+	t.Run("Large Field with Flat Labels", func(t *testing.T) {
+		e := logger.WithField("body", strings.Repeat("x", 2048))
+		e.Message = "test"
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithMaxEntryBytes(1024), WithLabelsMode(LabelsFlat), WithLabels(map[string]string{"svc": "api", "env": "prod"}))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.Equal(t, `{"label.env":"prod","label.svc":"api","message":"test`+TruncatedMarker+`","severity":"Info"}`+"\n", string(result))
+	})
+
+	t.Run("Large Message", func(t *testing.T) {
+		e := logger.WithField("prop", "value")
+		e.Message = strings.Repeat("日本語<>", 1000)
+		e.Level = logrus.InfoLevel
+
+		formatter := New(WithMaxEntryBytes(1024))
+		result, err := formatter.Format(e)
+		require.Nil(t, err)
+		assert.LessOrEqual(t, len(result), 1024)
+		assert.True(t, json.Valid(result))
+
+		output := map[string]interface{}{}
+		err = json.Unmarshal(result, &output)
+		require.Nil(t, err)
+		assert.NotContains(t, output, "prop")
+		assert.True(t, strings.HasPrefix(e.Message, strings.TrimSuffix(output[MessageKey].(string), TruncatedMarker)))
+		assert.True(t, strings.HasSuffix(output[MessageKey].(string), TruncatedMarker))
+	})
+}
@@ -0,0 +1,50 @@
+package gcfstructuredlogformatter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidLine is returned (wrapped) by `ValidateLine` for a line that is not a valid log entry.
+var ErrInvalidLine = errors.New("invalid log line")
+
+// ValidateLine checks that the line is a single log entry as the formatter writes it by default:
+// a JSON object followed by exactly one newline, with a `SeverityKey` that names a recognized severity
+// (in any case, so `UppercaseSeverity` is accepted) and a `MessageKey`.
+//
+// This is meant for tests that check every line that a service logs.
+// A formatter that renames these keys (such as with `FieldMap`) or that uses a custom `SeverityStringer`
+// writes lines that may not pass.
+func ValidateLine(line []byte) error {
+	if !bytes.HasSuffix(line, []byte("\n")) {
+		return fmt.Errorf("%w: missing trailing newline", ErrInvalidLine)
+	}
+	contents := line[:len(line)-1]
+	if bytes.ContainsAny(contents, "\r\n") {
+		return fmt.Errorf("%w: more than one line", ErrInvalidLine)
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidLine, err)
+	}
+
+	rawSeverity, okay := fields[SeverityKey]
+	if !okay {
+		return fmt.Errorf("%w: missing %q", ErrInvalidLine, SeverityKey)
+	}
+	var severity string
+	if err := json.Unmarshal(rawSeverity, &severity); err != nil {
+		return fmt.Errorf("%w: %q is not a string", ErrInvalidLine, SeverityKey)
+	}
+	if _, okay := parseSeverity(severity); !okay {
+		return fmt.Errorf("%w: unrecognized severity %q", ErrInvalidLine, severity)
+	}
+
+	if _, okay := fields[MessageKey]; !okay {
+		return fmt.Errorf("%w: missing %q", ErrInvalidLine, MessageKey)
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package gcfstructuredlogformatter
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLine(t *testing.T) {
+	rows := []struct {
+		description string
+		input       []byte
+		valid       bool
+	}{
+		{
+			description: "Valid",
+			input:       []byte(`{"message":"test","severity":"Info"}` + "\n"),
+			valid:       true,
+		},
+		{
+			description: "Uppercase Severity",
+			input:       []byte(`{"message":"test","severity":"WARNING"}` + "\n"),
+			valid:       true,
+		},
+		{
+			description: "Escaped Newline in Message",
+			input:       []byte(`{"message":"line 1\nline 2","severity":"Info"}` + "\n"),
+			valid:       true,
+		},
+		{
+			description: "Empty",
+			input:       []byte(``),
+		},
+		{
+			description: "No Trailing Newline",
+			input:       []byte(`{"message":"test","severity":"Info"}`),
+		},
+		{
+			description: "Two Trailing Newlines",
+			input:       []byte(`{"message":"test","severity":"Info"}` + "\n\n"),
+		},
+		{
+			description: "Two Lines",
+			input:       []byte(`{"message":"test","severity":"Info"}` + "\n" + `{"message":"test","severity":"Info"}` + "\n"),
+		},
+		{
+			description: "Not JSON",
+			input:       []byte(`message=test severity=Info` + "\n"),
+		},
+		{
+			description: "Not an Object",
+			input:       []byte(`["test"]` + "\n"),
+		},
+		{
+			description: "Missing Severity",
+			input:       []byte(`{"message":"test"}` + "\n"),
+		},
+		{
+			description: "Severity Not a String",
+			input:       []byte(`{"message":"test","severity":200}` + "\n"),
+		},
+		{
+			description: "Unrecognized Severity",
+			input:       []byte(`{"message":"test","severity":"Bogus"}` + "\n"),
+		},
+		{
+			description: "Missing Message",
+			input:       []byte(`{"severity":"Info"}` + "\n"),
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			err := ValidateLine(row.input)
+			if row.valid {
+				assert.Nil(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidLine)
+			}
+		})
+	}
+
+	t.Run("Formatter Output", func(t *testing.T) {
+		for _, level := range logrus.AllLevels {
+			e := logrus.NewEntry(logrus.New()).WithField("prop", "value")
+			e.Level = level
+			e.Message = "line 1\nline 2"
+
+			for _, formatter := range []*Formatter{New(), New(WithSeverityStringer(UppercaseSeverity))} {
+				result, err := formatter.Format(e)
+				require.Nil(t, err)
+				assert.Nil(t, ValidateLine(result), "level: %v", level)
+			}
+		}
+	})
+}
@@ -0,0 +1,221 @@
+// Package zapencoder provides a zap encoder that writes the same entries as the logrus formatter.
+//
+// The encoder converts each zap entry into a logrus entry and formats it with a `gcfstructuredlogformatter.Formatter`,
+// so the keys, severity mapping, labels, and trace handling are shared with the logrus output.
+// Since zap has no context of its own, use the `Context` field to provide one (for the trace, operation, and so on).
+package zapencoder
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// contextKey is the key of the field that carries the context (see `Context`).
+const contextKey = "gcfstructuredlogformatter.context"
+
+// LoggerKey is the key for the name of the zap logger.
+const LoggerKey = "logger"
+
+// bufferPool is the pool of buffers that the encoded entries are returned in.
+var bufferPool = buffer.NewPool()
+
+// zapToLogrusLevel maps a zap level to a logrus level.
+var zapToLogrusLevel = map[zapcore.Level]logrus.Level{
+	zapcore.DebugLevel:  logrus.DebugLevel,
+	zapcore.InfoLevel:   logrus.InfoLevel,
+	zapcore.WarnLevel:   logrus.WarnLevel,
+	zapcore.ErrorLevel:  logrus.ErrorLevel,
+	zapcore.DPanicLevel: logrus.ErrorLevel,
+	zapcore.PanicLevel:  logrus.PanicLevel,
+	zapcore.FatalLevel:  logrus.FatalLevel,
+}
+
+// contextMarshaler carries the context in a field; any other encoder writes it as an empty object.
+type contextMarshaler struct {
+	ctx context.Context
+}
+
+// MarshalLogObject writes nothing.
+func (m contextMarshaler) MarshalLogObject(zapcore.ObjectEncoder) error {
+	return nil
+}
+
+// Context returns a field that provides the context for the entry.
+//
+// The formatter reads the trace, operation, HTTP request, and so on from this context.
+// This may also be given to `zap.Logger.With`.
+func Context(ctx context.Context) zap.Field {
+	return zap.Object(contextKey, contextMarshaler{ctx: ctx})
+}
+
+// Encoder is a zap encoder that uses the logrus formatter.
+//
+// The fields added through `With` are kept as zap fields and encoded along with each entry.
+type Encoder struct {
+	formatter *gcfstructuredlogformatter.Formatter
+	fields    []zapcore.Field
+}
+
+var _ zapcore.Encoder = (*Encoder)(nil)
+
+// NewEncoder creates a new encoder that uses the given formatter.
+//
+// If the formatter is nil, then a default formatter is used.
+// The formatter's `ReportCaller` uses the caller from zap, so use `zap.AddCaller()` along with it.
+func NewEncoder(formatter *gcfstructuredlogformatter.Formatter) *Encoder {
+	if formatter == nil {
+		formatter = gcfstructuredlogformatter.New()
+	}
+	return &Encoder{
+		formatter: formatter,
+	}
+}
+
+// Clone copies the encoder.
+func (e *Encoder) Clone() zapcore.Encoder {
+	return &Encoder{
+		formatter: e.formatter,
+		fields:    append([]zapcore.Field(nil), e.fields...),
+	}
+}
+
+// EncodeEntry encodes an entry and its fields.
+func (e *Encoder) EncodeEntry(zapEntry zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	entry := logrus.NewEntry(logrus.StandardLogger())
+	entry.Message = zapEntry.Message
+	entry.Time = zapEntry.Time
+	entry.Level = logrus.ErrorLevel
+	if level, okay := zapToLogrusLevel[zapEntry.Level]; okay {
+		entry.Level = level
+	}
+	if zapEntry.Caller.Defined {
+		entry.Caller = &runtime.Frame{
+			PC:       zapEntry.Caller.PC,
+			File:     zapEntry.Caller.File,
+			Line:     zapEntry.Caller.Line,
+			Function: zapEntry.Caller.Function,
+		}
+	}
+
+	objectEncoder := zapcore.NewMapObjectEncoder()
+	for _, fieldList := range [][]zapcore.Field{e.fields, fields} {
+		for _, field := range fieldList {
+			if marshaler, okay := field.Interface.(contextMarshaler); okay && field.Key == contextKey {
+				entry.Context = marshaler.ctx
+				continue
+			}
+			field.AddTo(objectEncoder)
+		}
+	}
+	if zapEntry.LoggerName != "" {
+		objectEncoder.Fields[LoggerKey] = zapEntry.LoggerName
+	}
+	if zapEntry.Stack != "" {
+		objectEncoder.Fields[gcfstructuredlogformatter.StackTraceKey] = zapEntry.Stack
+	}
+	entry.Data = objectEncoder.Fields
+
+	contents, err := e.formatter.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	output := bufferPool.Get()
+	_, _ = output.Write(contents)
+	return output, nil
+}
+
+// add keeps a field for every entry.
+func (e *Encoder) add(field zapcore.Field) {
+	e.fields = append(e.fields, field)
+}
+
+// AddArray adds an array field.
+func (e *Encoder) AddArray(key string, marshaler zapcore.ArrayMarshaler) error {
+	e.add(zap.Array(key, marshaler))
+	return nil
+}
+
+// AddObject adds an object field.
+func (e *Encoder) AddObject(key string, marshaler zapcore.ObjectMarshaler) error {
+	e.add(zap.Object(key, marshaler))
+	return nil
+}
+
+// AddBinary adds a binary field.
+func (e *Encoder) AddBinary(key string, value []byte) { e.add(zap.Binary(key, value)) }
+
+// AddByteString adds a UTF-8 string field.
+func (e *Encoder) AddByteString(key string, value []byte) { e.add(zap.ByteString(key, value)) }
+
+// AddBool adds a boolean field.
+func (e *Encoder) AddBool(key string, value bool) { e.add(zap.Bool(key, value)) }
+
+// AddComplex128 adds a complex field.
+func (e *Encoder) AddComplex128(key string, value complex128) { e.add(zap.Complex128(key, value)) }
+
+// AddComplex64 adds a complex field.
+func (e *Encoder) AddComplex64(key string, value complex64) { e.add(zap.Complex64(key, value)) }
+
+// AddDuration adds a duration field.
+func (e *Encoder) AddDuration(key string, value time.Duration) { e.add(zap.Duration(key, value)) }
+
+// AddFloat64 adds a float field.
+func (e *Encoder) AddFloat64(key string, value float64) { e.add(zap.Float64(key, value)) }
+
+// AddFloat32 adds a float field.
+func (e *Encoder) AddFloat32(key string, value float32) { e.add(zap.Float32(key, value)) }
+
+// AddInt adds an integer field.
+func (e *Encoder) AddInt(key string, value int) { e.add(zap.Int(key, value)) }
+
+// AddInt64 adds an integer field.
+func (e *Encoder) AddInt64(key string, value int64) { e.add(zap.Int64(key, value)) }
+
+// AddInt32 adds an integer field.
+func (e *Encoder) AddInt32(key string, value int32) { e.add(zap.Int32(key, value)) }
+
+// AddInt16 adds an integer field.
+func (e *Encoder) AddInt16(key string, value int16) { e.add(zap.Int16(key, value)) }
+
+// AddInt8 adds an integer field.
+func (e *Encoder) AddInt8(key string, value int8) { e.add(zap.Int8(key, value)) }
+
+// AddString adds a string field.
+func (e *Encoder) AddString(key, value string) { e.add(zap.String(key, value)) }
+
+// AddTime adds a time field.
+func (e *Encoder) AddTime(key string, value time.Time) { e.add(zap.Time(key, value)) }
+
+// AddUint adds an unsigned integer field.
+func (e *Encoder) AddUint(key string, value uint) { e.add(zap.Uint(key, value)) }
+
+// AddUint64 adds an unsigned integer field.
+func (e *Encoder) AddUint64(key string, value uint64) { e.add(zap.Uint64(key, value)) }
+
+// AddUint32 adds an unsigned integer field.
+func (e *Encoder) AddUint32(key string, value uint32) { e.add(zap.Uint32(key, value)) }
+
+// AddUint16 adds an unsigned integer field.
+func (e *Encoder) AddUint16(key string, value uint16) { e.add(zap.Uint16(key, value)) }
+
+// AddUint8 adds an unsigned integer field.
+func (e *Encoder) AddUint8(key string, value uint8) { e.add(zap.Uint8(key, value)) }
+
+// AddUintptr adds a pointer field.
+func (e *Encoder) AddUintptr(key string, value uintptr) { e.add(zap.Uintptr(key, value)) }
+
+// AddReflected adds a field of any type.
+func (e *Encoder) AddReflected(key string, value interface{}) error {
+	e.add(zap.Reflect(key, value))
+	return nil
+}
+
+// OpenNamespace nests all of the subsequent fields under the given key.
+func (e *Encoder) OpenNamespace(key string) { e.add(zap.Namespace(key)) }
@@ -0,0 +1,128 @@
+package zapencoder
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tekkamanendless/gcfstructuredlogformatter"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// newLoggers returns a zap logger and a logrus logger that use the same formatter options.
+func newLoggers(options ...gcfstructuredlogformatter.Option) (*zap.Logger, *bytes.Buffer, *logrus.Logger, *bytes.Buffer) {
+	options = append([]gcfstructuredlogformatter.Option{gcfstructuredlogformatter.WithDisableTimestamp(true)}, options...)
+
+	var zapOutput bytes.Buffer
+	zapLogger := zap.New(zapcore.NewCore(NewEncoder(gcfstructuredlogformatter.New(options...)), zapcore.AddSync(&zapOutput), zapcore.DebugLevel))
+
+	var logrusOutput bytes.Buffer
+	logrusLogger := logrus.New()
+	logrusLogger.SetLevel(logrus.DebugLevel)
+	logrusLogger.SetOutput(&logrusOutput)
+	logrusLogger.SetFormatter(gcfstructuredlogformatter.New(options...))
+
+	return zapLogger, &zapOutput, logrusLogger, &logrusOutput
+}
+
+func TestEncoderMatchesFormatter(t *testing.T) {
+	ctx := context.WithValue(context.Background(), gcfstructuredlogformatter.ContextKeyTraceContext, gcfstructuredlogformatter.TraceContext{
+		TraceID: "105445aa7843bc8bf206b12000100000",
+		SpanID:  "0000000000000001",
+		Sampled: true,
+	})
+
+	rows := []struct {
+		description string
+		zap         func(logger *zap.Logger)
+		logrus      func(logger *logrus.Logger)
+		output      string
+	}{
+		{
+			description: "Message",
+			zap:         func(logger *zap.Logger) { logger.Info("test") },
+			logrus:      func(logger *logrus.Logger) { logger.Info("test") },
+			output:      `{"logging.googleapis.com/labels":{"service":"test"},"message":"test","severity":"Info"}` + "\n",
+		},
+		{
+			description: "Levels",
+			zap: func(logger *zap.Logger) {
+				logger.Debug("debug")
+				logger.Warn("warn")
+				logger.Error("error")
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.Debug("debug")
+				logger.Warn("warn")
+				logger.Error("error")
+			},
+			output: `{"logging.googleapis.com/labels":{"service":"test"},"message":"debug","severity":"Debug"}` + "\n" +
+				`{"logging.googleapis.com/labels":{"service":"test"},"message":"warn","severity":"Warning"}` + "\n" +
+				`{"logging.googleapis.com/labels":{"service":"test"},"message":"error","severity":"Error"}` + "\n",
+		},
+		{
+			description: "Fields",
+			zap: func(logger *zap.Logger) {
+				logger.With(zap.String("key", "value")).Info("test", zap.Int("count", 2), zap.Bool("okay", true), zap.Duration("latency", time.Second))
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.WithField("key", "value").WithFields(logrus.Fields{"count": 2, "okay": true, "latency": time.Second}).Info("test")
+			},
+			output: `{"count":2,"key":"value","latency":1000000000,"logging.googleapis.com/labels":{"service":"test"},"message":"test","okay":true,"severity":"Info"}` + "\n",
+		},
+		{
+			description: "Context",
+			zap: func(logger *zap.Logger) {
+				logger.Info("first", Context(ctx))
+				logger.With(Context(ctx)).Info("second")
+			},
+			logrus: func(logger *logrus.Logger) {
+				logger.WithContext(ctx).Info("first")
+				logger.WithContext(ctx).Info("second")
+			},
+			output: `{"logging.googleapis.com/labels":{"service":"test"},"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"first","severity":"Info"}` + "\n" +
+				`{"logging.googleapis.com/labels":{"service":"test"},"logging.googleapis.com/spanId":"0000000000000001","logging.googleapis.com/trace":"105445aa7843bc8bf206b12000100000","logging.googleapis.com/trace_sampled":true,"message":"second","severity":"Info"}` + "\n",
+		},
+	}
+
+	for _, row := range rows {
+		t.Run(row.description, func(t *testing.T) {
+			zapLogger, zapOutput, logrusLogger, logrusOutput := newLoggers(gcfstructuredlogformatter.WithLabels(map[string]string{"service": "test"}))
+
+			row.zap(zapLogger)
+			row.logrus(logrusLogger)
+			assert.Equal(t, row.output, zapOutput.String())
+			assert.Equal(t, logrusOutput.String(), zapOutput.String())
+		})
+	}
+}
+
+func TestEncoderCaller(t *testing.T) {
+	var output bytes.Buffer
+	formatter := gcfstructuredlogformatter.New(gcfstructuredlogformatter.WithDisableTimestamp(true), gcfstructuredlogformatter.WithReportCaller(true))
+	logger := zap.New(zapcore.NewCore(NewEncoder(formatter), zapcore.AddSync(&output), zapcore.DebugLevel), zap.AddCaller())
+
+	logger.Info("test")
+
+	entry, err := gcfstructuredlogformatter.ParseEntry(output.Bytes())
+	require.Nil(t, err)
+	require.NotNil(t, entry.SourceLocation)
+	assert.Contains(t, entry.SourceLocation.File, "encoder_test.go")
+	assert.Contains(t, entry.SourceLocation.Function, "TestEncoderCaller")
+}
+
+func TestEncoderLoggerName(t *testing.T) {
+	var output bytes.Buffer
+	logger := zap.New(zapcore.NewCore(NewEncoder(nil), zapcore.AddSync(&output), zapcore.DebugLevel)).Named("worker")
+
+	logger.Info("test")
+
+	entry, err := gcfstructuredlogformatter.ParseEntry(output.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, "worker", entry.Fields[LoggerKey])
+}